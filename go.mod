@@ -4,6 +4,8 @@ go 1.23
 
 require github.com/lib/pq v1.10.9
 
+require github.com/google/uuid v1.6.0 // indirect
+
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -0,0 +1,715 @@
+package generator
+
+import "text/template"
+
+// filterSpec describes a generated scalar filter type and the operators it supports.
+type filterSpec struct {
+	Name    string // Go type name, e.g. "StringFilter"
+	Type    string // Go type of the filtered value
+	Label   string // Schema type, used in doc comments
+	List    bool   // In and NotIn
+	Ordered bool   // Lt, Lte, Gt and Gte
+	Text    bool   // Contains, StartsWith, EndsWith and Mode
+}
+
+// scalarFilters lists the filter types for scalar Go types. Fields of other
+// types (Json, Bytes, lists) cannot be filtered.
+var scalarFilters = []filterSpec{
+	{Name: "StringFilter", Type: "string", Label: "String", List: true, Ordered: true, Text: true},
+	{Name: "IntFilter", Type: "int", Label: "Int", List: true, Ordered: true},
+	{Name: "BigIntFilter", Type: "int64", Label: "BigInt", List: true, Ordered: true},
+	{Name: "FloatFilter", Type: "float64", Label: "Float or Decimal", List: true, Ordered: true},
+	{Name: "BoolFilter", Type: "bool", Label: "Boolean"},
+	{Name: "TimeFilter", Type: "time.Time", Label: "DateTime", List: true, Ordered: true},
+	{Name: "UUIDFilter", Type: "uuid.UUID", Label: "UUID", List: true},
+}
+
+// filterType returns the name of the filter type for f, or "" if f cannot be filtered.
+func filterType(f Field) string {
+	if f.EnumValues != nil {
+		return f.Type + "Filter"
+	}
+	for _, spec := range scalarFilters {
+		if spec.Type == f.Type {
+			return spec.Name
+		}
+	}
+	return ""
+}
+
+// updateType returns the type of the update operations of f in an update input.
+func updateType(f Field) string {
+	switch f.Type {
+	case "int", "int64", "float64":
+		return "NumberUpdate[" + f.Type + "]"
+	}
+	return "FieldUpdate[" + f.Type + "]"
+}
+
+// usedFilters returns the filter types referenced by the schema's models.
+func usedFilters(ast AST) []filterSpec {
+	used := map[string]bool{}
+	for _, ent := range ast.Entities {
+		for _, f := range ent.Fields {
+			used[filterType(f)] = true
+		}
+	}
+	var specs []filterSpec
+	for _, spec := range scalarFilters {
+		if used[spec.Name] {
+			specs = append(specs, spec)
+		}
+	}
+	for _, enum := range ast.Enums {
+		if used[enum.Name+"Filter"] {
+			specs = append(specs, filterSpec{Name: enum.Name + "Filter", Type: enum.Name, Label: enum.Name, List: true})
+		}
+	}
+	return specs
+}
+
+// hasRelations reports whether any model of the schema declares a relation.
+func hasRelations(ast AST) bool {
+	for _, ent := range ast.Entities {
+		if len(ent.Relations) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+var filterTemplate = template.Must(template.New("filters").Parse(`package models
+
+// Code generated by TORM; DO NOT EDIT.
+
+import (
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+{{- range .Filters }}
+{{- if eq .Type "time.Time" }}
+    "time"
+{{- end }}
+{{- end }}
+{{- range .Filters }}
+{{- if eq .Type "uuid.UUID" }}
+
+    "github.com/google/uuid"
+{{- end }}
+{{- end }}
+)
+
+// Ptr returns a pointer to v, for use in filters and inputs.
+func Ptr[T any](v T) *T {
+    return &v
+}
+
+// QueryMode selects how string filters compare text.
+type QueryMode int
+
+const (
+    // ModeDefault compares strings as stored.
+    ModeDefault QueryMode = iota
+    // ModeInsensitive compares strings case-insensitively.
+    ModeInsensitive
+)
+
+// SortOrder is the direction of an ordering.
+type SortOrder int
+
+const (
+    Asc SortOrder = iota
+    Desc
+)
+
+// NullsOrder places NULL values before or after the others. By default
+// PostgreSQL sorts NULL values as larger than any other value.
+type NullsOrder int
+
+const (
+    NullsDefault NullsOrder = iota
+    First
+    Last
+)
+
+// orderTerm renders expr as an ORDER BY term.
+func orderTerm(expr string, dir SortOrder, nulls NullsOrder) string {
+    if dir == Desc {
+        expr += " DESC"
+    }
+    switch nulls {
+    case First:
+        expr += " NULLS FIRST"
+    case Last:
+        expr += " NULLS LAST"
+    }
+    return expr
+}
+
+// ErrInvalidCursor is returned when a cursor token cannot be decoded or was
+// made for a different ordering.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// orderKey is a column of a keyset ordering.
+type orderKey struct {
+    col      string // column name
+    expr     string // qualified, quoted column
+    dir      SortOrder
+    nulls    NullsOrder
+    nullable bool
+}
+
+// nullsFirst reports whether NULL values of k sort before the others, which
+// PostgreSQL does by default for descending orders.
+func (k orderKey) nullsFirst() bool {
+    return k.nulls == First || (k.nulls == NullsDefault && k.dir == Desc)
+}
+
+// cursorToken is the decoded form of a cursor: the columns of the ordering
+// and their values on the last record of a page.
+type cursorToken struct {
+    Cols []string      ` + "`" + `json:"c"` + "`" + `
+    Vals []interface{} ` + "`" + `json:"v"` + "`" + `
+}
+
+func encodeCursor(keys []orderKey, vals []interface{}) (string, error) {
+    tok := cursorToken{Cols: make([]string, len(keys)), Vals: vals}
+    for i, k := range keys {
+        tok.Cols[i] = k.col
+    }
+    data, err := json.Marshal(tok)
+    if err != nil {
+        return "", err
+    }
+    return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(token string, keys []orderKey) ([]interface{}, error) {
+    data, err := base64.RawURLEncoding.DecodeString(token)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+    }
+    dec := json.NewDecoder(bytes.NewReader(data))
+    // Numbers are kept as text so that large integers survive the round trip.
+    dec.UseNumber()
+    var tok cursorToken
+    if err := dec.Decode(&tok); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+    }
+    if len(tok.Cols) != len(keys) || len(tok.Vals) != len(keys) {
+        return nil, fmt.Errorf("%w: made for a different ordering", ErrInvalidCursor)
+    }
+    for i, k := range keys {
+        if tok.Cols[i] != k.col {
+            return nil, fmt.Errorf("%w: made for a different ordering", ErrInvalidCursor)
+        }
+        if n, ok := tok.Vals[i].(json.Number); ok {
+            tok.Vals[i] = string(n)
+        }
+    }
+    return tok.Vals, nil
+}
+
+// after adds the condition selecting the rows that sort after vals in the
+// order of keys. Rows are compared as a whole, e.g. (a, b) > ($1, $2), when all
+// keys share a direction and cannot be NULL; otherwise the comparison is
+// expanded column by column.
+func after(b *whereBuilder, keys []orderKey, vals []interface{}) {
+    rowWise := true
+    for _, k := range keys {
+        if k.dir != keys[0].dir || k.nullable {
+            rowWise = false
+        }
+    }
+    if rowWise {
+        exprs := make([]string, len(keys))
+        phs := make([]string, len(keys))
+        for i, k := range keys {
+            exprs[i], phs[i] = k.expr, b.arg(vals[i])
+        }
+        op := ">"
+        if keys[0].dir == Desc {
+            op = "<"
+        }
+        b.add(fmt.Sprintf("(%s) %s (%s)", strings.Join(exprs, ", "), op, strings.Join(phs, ", ")))
+        return
+    }
+    var alts, equal []string
+    for i, k := range keys {
+        if cond := sortsAfter(b, k, vals[i]); cond != "" {
+            alts = append(alts, "("+strings.Join(append(equal[:len(equal):len(equal)], cond), " AND ")+")")
+        }
+        if i == len(keys)-1 {
+            break
+        }
+        if vals[i] == nil {
+            equal = append(equal, k.expr+" IS NULL")
+        } else {
+            equal = append(equal, k.expr+" = "+b.arg(vals[i]))
+        }
+    }
+    if len(alts) == 0 {
+        b.add("FALSE")
+        return
+    }
+    b.add("(" + strings.Join(alts, " OR ") + ")")
+}
+
+// sortsAfter returns the condition on column k of rows that sort after v, or
+// "" if none do.
+func sortsAfter(b *whereBuilder, k orderKey, v interface{}) string {
+    if v == nil {
+        if k.nullsFirst() {
+            return k.expr + " IS NOT NULL"
+        }
+        return ""
+    }
+    op := ">"
+    if k.dir == Desc {
+        op = "<"
+    }
+    cond := fmt.Sprintf("%s %s %s", k.expr, op, b.arg(v))
+    if k.nullable && !k.nullsFirst() {
+        cond = "(" + cond + " OR " + k.expr + " IS NULL)"
+    }
+    return cond
+}
+{{- range .Filters }}
+
+// {{ .Name }} filters a {{ .Label }} field. Set operators are combined with AND.
+type {{ .Name }} struct {
+    Equals *{{ .Type }}
+    Not    *{{ .Type }}
+{{- if .List }}
+    In     []{{ .Type }}
+    NotIn  []{{ .Type }}
+{{- end }}
+{{- if .Ordered }}
+    Lt     *{{ .Type }}
+    Lte    *{{ .Type }}
+    Gt     *{{ .Type }}
+    Gte    *{{ .Type }}
+{{- end }}
+{{- if .Text }}
+    Contains   *string
+    StartsWith *string
+    EndsWith   *string
+    Mode       QueryMode
+{{- end }}
+    IsNull *bool
+}
+
+func (f {{ .Name }}) build(b *whereBuilder, col string) {
+{{- if .Text }}
+    lhs, rhs, like := col, "%s", "LIKE"
+    if f.Mode == ModeInsensitive {
+        lhs, rhs, like = "LOWER("+col+")", "LOWER(%s)", "ILIKE"
+    }
+{{- else }}
+    lhs, rhs := col, "%s"
+{{- end }}
+    compare(b, lhs, rhs, "=", f.Equals)
+    compare(b, lhs, rhs, "<>", f.Not)
+{{- if .List }}
+    inList(b, lhs, rhs, "IN", f.In)
+    inList(b, lhs, rhs, "NOT IN", f.NotIn)
+{{- end }}
+{{- if .Ordered }}
+    compare(b, lhs, rhs, "<", f.Lt)
+    compare(b, lhs, rhs, "<=", f.Lte)
+    compare(b, lhs, rhs, ">", f.Gt)
+    compare(b, lhs, rhs, ">=", f.Gte)
+{{- end }}
+{{- if .Text }}
+    match(b, col, like, "%", f.Contains, "%")
+    match(b, col, like, "", f.StartsWith, "%")
+    match(b, col, like, "%", f.EndsWith, "")
+{{- end }}
+    isNull(b, col, f.IsNull)
+}
+{{- end }}
+
+// FieldUpdate sets a field to a value, or an optional field to NULL.
+type FieldUpdate[T any] struct {
+    Set     *T
+    SetNull bool
+}
+
+func (u FieldUpdate[T]) build(b *whereBuilder, col string, nullable bool) error {
+    var sets []string
+    if u.Set != nil {
+        sets = append(sets, b.arg(*u.Set))
+    }
+    if u.SetNull {
+        sets = append(sets, "NULL")
+    }
+    return assign(b, col, nullable, u.SetNull, sets)
+}
+
+// NumberUpdate sets a numeric field, or changes it atomically relative to
+// its current value.
+type NumberUpdate[T int | int64 | float64] struct {
+    Set       *T
+    SetNull   bool
+    Increment *T
+    Decrement *T
+    Multiply  *T
+    Divide    *T
+}
+
+func (u NumberUpdate[T]) build(b *whereBuilder, col string, nullable bool) error {
+    q := quoteIdent(col)
+    if b.table != "" {
+        q = b.table + "." + q
+    }
+    var sets []string
+    if u.Set != nil {
+        sets = append(sets, b.arg(*u.Set))
+    }
+    if u.SetNull {
+        sets = append(sets, "NULL")
+    }
+    ops := []string{"+", "-", "*", "/"}
+    for i, v := range []*T{u.Increment, u.Decrement, u.Multiply, u.Divide} {
+        if v != nil {
+            sets = append(sets, fmt.Sprintf("%s %s %s", q, ops[i], b.arg(*v)))
+        }
+    }
+    return assign(b, col, nullable, u.SetNull, sets)
+}
+
+// assign adds "col = value" for the single operation in values, if any.
+func assign(b *whereBuilder, col string, nullable, setNull bool, values []string) error {
+    switch {
+    case len(values) > 1:
+        return fmt.Errorf("%s: only one update operation may be set", col)
+    case setNull && !nullable:
+        return fmt.Errorf("%s: cannot set a required field to NULL", col)
+    case len(values) == 1:
+        b.add(quoteIdent(col) + " = " + values[0])
+    }
+    return nil
+}
+
+// whereBuilder accumulates SQL conditions and their arguments, numbering
+// placeholders across the whole statement.
+type whereBuilder struct {
+    conds []string
+    args  []interface{}
+    alias string // alias of the filtered table inside a subquery
+    depth int    // subquery nesting level
+    table string // qualifies current column values in ON CONFLICT DO UPDATE
+}
+
+// qualifier returns the name columns of table are qualified with.
+func (b *whereBuilder) qualifier(table string) string {
+    if b.alias != "" {
+        return b.alias
+    }
+    return table
+}
+
+// arg binds v and returns its placeholder.
+func (b *whereBuilder) arg(v interface{}) string {
+    b.args = append(b.args, v)
+    return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *whereBuilder) add(cond string) {
+    b.conds = append(b.conds, cond)
+}
+
+// where returns the conditions as a WHERE clause, or "" if there are none.
+func (b *whereBuilder) where() string {
+    if len(b.conds) == 0 {
+        return ""
+    }
+    return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// group builds a nested condition with fn, sharing b's placeholders, and
+// returns it parenthesized, or "" if fn added no conditions.
+func (b *whereBuilder) group(fn func(*whereBuilder)) string {
+    sub := &whereBuilder{args: b.args, alias: b.alias, depth: b.depth}
+    fn(sub)
+    b.args = sub.args
+    if len(sub.conds) == 0 {
+        return ""
+    }
+    return "(" + strings.Join(sub.conds, " AND ") + ")"
+}
+
+// condition is implemented by the generated Where types.
+type condition interface {
+    build(b *whereBuilder)
+}
+
+// allOf adds the conditions of every w.
+func allOf[W condition](b *whereBuilder, ws []W) {
+    for _, w := range ws {
+        w.build(b)
+    }
+}
+
+// anyOf adds a disjunction of ws if ws is non-nil. An empty list matches
+// nothing and an empty element matches everything.
+func anyOf[W condition](b *whereBuilder, ws []W) {
+    if ws == nil {
+        return
+    }
+    parts := make([]string, len(ws))
+    for i, w := range ws {
+        if parts[i] = b.group(w.build); parts[i] == "" {
+            parts[i] = "TRUE"
+        }
+    }
+    if len(parts) == 0 {
+        b.add("FALSE")
+        return
+    }
+    b.add("(" + strings.Join(parts, " OR ") + ")")
+}
+
+// noneOf adds the negation of every w. Empty elements are ignored.
+func noneOf[W condition](b *whereBuilder, ws []W) {
+    for _, w := range ws {
+        if cond := b.group(w.build); cond != "" {
+            b.add("NOT " + cond)
+        }
+    }
+}
+
+{{- if .Relations }}
+
+// RelationFilter filters on the record of a singular relation.
+type RelationFilter[W condition] struct {
+    Is    *W // the related record exists and matches
+    IsNot *W // no related record matches
+}
+
+func (f RelationFilter[W]) build(b *whereBuilder, outer string, l link) {
+    if f.Is != nil {
+        b.exists(outer, l, false, (*f.Is).build, false)
+    }
+    if f.IsNot != nil {
+        b.exists(outer, l, true, (*f.IsNot).build, false)
+    }
+}
+
+// ListRelationFilter filters on the records of a list relation.
+type ListRelationFilter[W condition] struct {
+    Some  *W // at least one related record matches
+    Every *W // all related records match
+    None  *W // no related record matches
+}
+
+func (f ListRelationFilter[W]) build(b *whereBuilder, outer string, l link) {
+    if f.Some != nil {
+        b.exists(outer, l, false, (*f.Some).build, false)
+    }
+    if f.Every != nil {
+        b.exists(outer, l, true, (*f.Every).build, true)
+    }
+    if f.None != nil {
+        b.exists(outer, l, true, (*f.None).build, false)
+    }
+}
+
+// ConnectOrCreate connects the record matching Where, or creates it from
+// Create if there is none.
+type ConnectOrCreate[C, U any] struct {
+    Where  U
+    Create C
+}
+
+// RelationCreate is a nested write of a singular relation in a create input.
+// At most one operation may be set.
+type RelationCreate[C, U any] struct {
+    Create          *C                     // create a new related record
+    ConnectOrCreate *ConnectOrCreate[C, U] // connect a record, creating it if needed
+    Connect         *U                     // connect an existing record
+}
+
+func (r RelationCreate[C, U]) set() bool {
+    return r.Create != nil || r.ConnectOrCreate != nil || r.Connect != nil
+}
+
+func (r RelationCreate[C, U]) update() RelationUpdate[C, U] {
+    return RelationUpdate[C, U]{Create: r.Create, ConnectOrCreate: r.ConnectOrCreate, Connect: r.Connect}
+}
+
+// RelationUpdate is a nested write of a singular relation in an update input.
+// At most one operation may be set.
+type RelationUpdate[C, U any] struct {
+    Create          *C                     // create a new related record
+    ConnectOrCreate *ConnectOrCreate[C, U] // connect a record, creating it if needed
+    Connect         *U                     // connect an existing record
+    Disconnect      bool                   // disconnect the related record
+}
+
+func (r RelationUpdate[C, U]) set() bool {
+    return r.Create != nil || r.ConnectOrCreate != nil || r.Connect != nil || r.Disconnect
+}
+
+// check returns an error if more than one operation of r is set.
+func (r RelationUpdate[C, U]) check() error {
+    n := 0
+    for _, set := range []bool{r.Create != nil, r.ConnectOrCreate != nil, r.Connect != nil, r.Disconnect} {
+        if set {
+            n++
+        }
+    }
+    if n > 1 {
+        return errors.New("only one nested write may be set on a relation")
+    }
+    return nil
+}
+
+// ListRelationCreate is a nested write of a list relation in a create input.
+type ListRelationCreate[C, U any] struct {
+    Create          []C                     // create new related records
+    ConnectOrCreate []ConnectOrCreate[C, U] // connect records, creating them if needed
+    Connect         []U                     // connect existing records
+}
+
+func (r ListRelationCreate[C, U]) set() bool {
+    return len(r.Create) > 0 || len(r.ConnectOrCreate) > 0 || len(r.Connect) > 0
+}
+
+func (r ListRelationCreate[C, U]) update() ListRelationUpdate[C, U] {
+    return ListRelationUpdate[C, U]{Create: r.Create, ConnectOrCreate: r.ConnectOrCreate, Connect: r.Connect}
+}
+
+// ListRelationUpdate is a nested write of a list relation in an update input.
+// Set and Disconnect are applied first, then the other operations.
+type ListRelationUpdate[C, U any] struct {
+    Create          []C                     // create new related records
+    ConnectOrCreate []ConnectOrCreate[C, U] // connect records, creating them if needed
+    Connect         []U                     // connect existing records
+    Disconnect      []U                     // disconnect records
+    Set             []U                     // if non-nil, replace the related records with these
+}
+
+func (r ListRelationUpdate[C, U]) set() bool {
+    return len(r.Create) > 0 || len(r.ConnectOrCreate) > 0 || len(r.Connect) > 0 || len(r.Disconnect) > 0 || r.Set != nil
+}
+
+// link describes how the rows of a related table are matched to the filtered
+// row: the remote columns equal the filtered table's local columns, pairwise.
+// Many-to-many relations match through a join table, whose throughKey column
+// references the related table's targetKey.
+type link struct {
+    table                 string
+    local, remote         []string
+    through               string
+    throughKey, targetKey string
+}
+
+// on renders the conditions matching rows of alias to the outer row.
+func (l link) on(alias, outer string) string {
+    conds := make([]string, len(l.local))
+    for i := range l.local {
+        conds[i] = fmt.Sprintf("%s.%s = %s.%s", alias, quoteIdent(l.remote[i]), outer, quoteIdent(l.local[i]))
+    }
+    return strings.Join(conds, " AND ")
+}
+
+// orderByRelation orders by expr evaluated on the record related to outer
+// through l, using a scalar subquery.
+func orderByRelation(outer string, depth int, l link, expr func(string, int) (string, SortOrder, NullsOrder, error)) (string, SortOrder, NullsOrder, error) {
+    alias := fmt.Sprintf("o%d", depth+1)
+    e, dir, nulls, err := expr(alias, depth+1)
+    if err != nil {
+        return "", 0, 0, err
+    }
+    return fmt.Sprintf("(SELECT %s FROM %s %s WHERE %s)", e, quoteIdent(l.table), alias, l.on(alias, outer)), dir, nulls, nil
+}
+
+// orderByCount returns a subquery counting the rows related to outer through l.
+func orderByCount(outer string, depth int, l link) string {
+    alias := fmt.Sprintf("o%d", depth+1)
+    return fmt.Sprintf("(SELECT COUNT(*) FROM %s %s WHERE %s)", quoteIdent(l.table), alias, l.on(alias, outer))
+}
+
+// exists adds an EXISTS subquery over the rows related to outer through l
+// that match fn, or that do not match fn if negateCond is set. The subquery is
+// negated with NOT if negate is set.
+func (b *whereBuilder) exists(outer string, l link, negate bool, fn func(*whereBuilder), negateCond bool) {
+    sub := &whereBuilder{args: b.args, depth: b.depth + 1}
+    sub.alias = fmt.Sprintf("r%d", sub.depth)
+    from, joined := quoteIdent(l.table)+" "+sub.alias, sub.alias
+    if l.through != "" {
+        joined = sub.alias + "j"
+        from += fmt.Sprintf(" JOIN %s %s ON %s.%s = %s.%s", quoteIdent(l.through), joined, joined, quoteIdent(l.throughKey), sub.alias, quoteIdent(l.targetKey))
+    }
+    sub.add(l.on(joined, outer))
+    cond := sub.group(fn)
+    if negateCond {
+        if cond == "" {
+            // every related record matches an empty filter
+            b.args = sub.args
+            return
+        }
+        cond = "NOT " + cond
+    }
+    if cond != "" {
+        sub.add(cond)
+    }
+    b.args = sub.args
+    op := "EXISTS"
+    if negate {
+        op = "NOT EXISTS"
+    }
+    b.add(fmt.Sprintf("%s (SELECT 1 FROM %s WHERE %s)", op, from, strings.Join(sub.conds, " AND ")))
+}
+{{- end }}
+
+// compare adds "lhs op value" if v is set; rhs wraps the placeholder.
+func compare[T any](b *whereBuilder, lhs, rhs, op string, v *T) {
+    if v != nil {
+        b.add(fmt.Sprintf("%s %s "+rhs, lhs, op, b.arg(*v)))
+    }
+}
+
+// inList adds "lhs IN (...)" or "lhs NOT IN (...)" if vs is non-nil. An empty
+// IN list matches nothing and an empty NOT IN list matches everything.
+func inList[T any](b *whereBuilder, lhs, rhs, op string, vs []T) {
+    if vs == nil {
+        return
+    }
+    if len(vs) == 0 {
+        if op == "IN" {
+            b.add("FALSE")
+        }
+        return
+    }
+    phs := make([]string, len(vs))
+    for i, v := range vs {
+        phs[i] = fmt.Sprintf(rhs, b.arg(v))
+    }
+    b.add(fmt.Sprintf("%s %s (%s)", lhs, op, strings.Join(phs, ", ")))
+}
+
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// match adds a LIKE condition for v, escaping its wildcards.
+func match(b *whereBuilder, col, like, prefix string, v *string, suffix string) {
+    if v != nil {
+        b.add(fmt.Sprintf("%s %s %s", col, like, b.arg(prefix+likeEscaper.Replace(*v)+suffix)))
+    }
+}
+
+func isNull(b *whereBuilder, col string, v *bool) {
+    if v == nil {
+        return
+    }
+    if *v {
+        b.add(col + " IS NULL")
+    } else {
+        b.add(col + " IS NOT NULL")
+    }
+}
+`))
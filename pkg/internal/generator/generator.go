@@ -34,12 +34,23 @@ var modelTemplate = template.Must(template.New("model").
 			}
 			return false
 		},
-		"goType": func(t string) string {
-			if strings.HasSuffix(t, "[]") {
-				return "[]" + strings.TrimSuffix(t, "[]")
+		"hasJSON": func(fields []Field) bool {
+			for _, f := range fields {
+				if f.Type == "json.RawMessage" {
+					return true
+				}
+			}
+			return false
+		},
+		"usesSQLNull": func(optionalTypes string, fields []Field) bool {
+			for _, f := range fields {
+				if strings.HasPrefix(goFieldType(optionalTypes, f), "sql.") {
+					return true
+				}
 			}
-			return t
+			return false
 		},
+		"fieldType": goFieldType,
 		"export": func(s string) string {
 			if len(s) == 0 {
 				return s
@@ -52,6 +63,12 @@ var modelTemplate = template.Must(template.New("model").
 // Code generated by TORM; DO NOT EDIT.
 		
 import (
+{{- if usesSQLNull .OptionalTypes .Fields }}
+    "database/sql"
+{{- end }}
+{{- if hasJSON .Fields }}
+    "encoding/json"
+{{- end }}
 {{- if hasUUID .Fields }}
     "github.com/google/uuid"
 {{- end }}
@@ -62,10 +79,14 @@ import (
 
 type {{ .Name }} struct {
 {{- range .Fields }}
-    {{ export .Name }} {{ goType .Type }}
+    {{ export .Name }} {{ fieldType $.OptionalTypes . }}
 {{- end }}
 {{- range .Relations }}
+{{- if .List }}
     {{ export .Name }} []{{ .Type }}
+{{- else }}
+    {{ export .Name }} *{{ .Type }}
+{{- end }}
 {{- end }}
 }
 `))
@@ -73,12 +94,53 @@ type {{ .Name }} struct {
 var clientTemplate = template.Must(template.New("client").
 	Funcs(template.FuncMap{
 		"lower": strings.ToLower,
+		"unexport": func(s string) string {
+			if len(s) == 0 {
+				return s
+			}
+			return strings.ToLower(s[:1]) + s[1:]
+		},
 		"export": func(s string) string {
 			if len(s) == 0 {
 				return s
 			}
 			return strings.ToUpper(s[:1]) + s[1:]
 		},
+		"join":         strings.Join,
+		"relationJoin": joinFor,
+		"primaryKey":   primaryKey,
+		"primaryKeys":  primaryKeys,
+		"isPrimaryKey": isPrimaryKey,
+		"compoundKeys": compoundKeys,
+		"filterType":   filterType,
+		"updateType":   updateType,
+		"hasListRelation": func(ent Entity) bool {
+			for _, rel := range ent.Relations {
+				if rel.List {
+					return true
+				}
+			}
+			return false
+		},
+		"hasUpdatedAt": func(ent Entity) bool {
+			for _, f := range ent.Fields {
+				if f.UpdatedAt && !f.PrimaryKey {
+					return true
+				}
+			}
+			return false
+		},
+		"hasDefault":   hasDefault,
+		"writeKind":    writeKind,
+		"nestedWrites": nestedWrites,
+		"anyRequired":  anyRequired,
+		"fieldType":    goFieldType,
+		"valueExpr":    valueExpr,
+		"nullExpr":     nullExpr,
+		"entity":       entityByName,
+		"uniqueFields": uniqueFields,
+		"compoundName": compoundName,
+		"fieldOf":      fieldOf,
 	}).
 	Parse(`package models
 
@@ -87,25 +149,38 @@ var clientTemplate = template.Must(template.New("client").
 import (
     "context"
     "database/sql"
+{{- if .HasJSON }}
+    "encoding/json"
+{{- end }}
+    "errors"
     "fmt"
     "io/ioutil"
+    "iter"
     "os"
-    "reflect"
     "regexp"
+    "sort"
     "strings"
     "sync"
     "time"
 
-    _ "github.com/lib/pq"
+{{- if .HasUUID }}
+    "github.com/google/uuid"
+{{- end }}
+    "github.com/TechXTT/TORM/pkg/runtime"
+    "github.com/lib/pq"
 )
 
+// arrayParams reports whether the datasource accepts array parameters, so
+// relations can be batch-loaded with = ANY($1).
+const arrayParams = {{ .ArrayParams }}
 
 // Client wraps a database connection and provides per-model services.
 type Client struct {
-    dsn  string
-    db   *sql.DB
-    once sync.Once
-    err  error
+    dsn     string
+    session *runtime.Session
+    now     func() time.Time
+    once    sync.Once
+    err     error
 }
 
 // NewClient reads DSN from prisma/schema.prisma and returns a Client.
@@ -128,214 +203,584 @@ func NewClient() *Client {
         if strings.Contains(dsn, "?") { sep = "&" }
         dsn += sep + "sslmode=disable"
     }
-    return &Client{dsn: dsn}
+    return &Client{dsn: dsn, now: time.Now}
+}
+
+// NewClientFromSession returns a Client that runs on s instead of connecting
+// to the datasource URL of the schema.
+func NewClientFromSession(s *runtime.Session) *Client {
+    c := &Client{session: s, now: time.Now}
+    c.once.Do(func() {})
+    return c
+}
+
+// WithClock makes the client read the current time, used for @updatedAt
+// fields, from now instead of time.Now. It returns c.
+func (c *Client) WithClock(now func() time.Time) *Client {
+    c.now = now
+    return c
 }
 
 // connect opens the DB once.
-func (c *Client) connect() (*sql.DB, error) {
+func (c *Client) connect() (*runtime.Session, error) {
     c.once.Do(func() {
-        c.db, c.err = sql.Open("postgres", c.dsn)
-        if c.err != nil { return }
-        c.err = c.db.PingContext(context.Background())
+        db, err := sql.Open("postgres", c.dsn)
+        if err != nil {
+            c.err = err
+            return
+        }
+        if c.err = db.PingContext(context.Background()); c.err == nil {
+            c.session = runtime.NewSession(db)
+        }
     })
-    return c.db, c.err
+    return c.session, c.err
 }
-        
 
-// TimeOrZero implements sql.Scanner for time.Time fields, converting NULL to zero time.
-type TimeOrZero time.Time
+// Executor runs statements against a database. It is implemented by *sql.DB,
+// *sql.Tx, *sql.Conn and *runtime.Session.
+type Executor = runtime.Executor
 
-// Scan implements the sql.Scanner interface.
-func (t *TimeOrZero) Scan(value interface{}) error {
-    if value == nil {
-        *t = TimeOrZero(time.Time{})
-        return nil
+// TxClient provides per-model services that run inside a transaction.
+type TxClient struct {
+    session *runtime.Session
+    now     func() time.Time
+}
+
+// TxOption configures a transaction started by Client.Transaction.
+type TxOption func(*sql.TxOptions)
+
+// WithIsolation sets the isolation level of the transaction.
+func WithIsolation(level sql.IsolationLevel) TxOption {
+    return func(o *sql.TxOptions) {
+        o.Isolation = level
     }
-    tm, ok := value.(time.Time)
-    if !ok {
-        return fmt.Errorf("cannot scan type %T into TimeOrZero", value)
+}
+
+// ReadOnly starts a read-only transaction.
+func ReadOnly() TxOption {
+    return func(o *sql.TxOptions) {
+        o.ReadOnly = true
     }
-    *t = TimeOrZero(tm)
-    return nil
 }
 
-// scanDest returns a slice of destination pointers for scanning into struct fields.
-// It substitutes sql.NullString for any string field, and TimeOrZero for any time.Time field.
-func scanDest(m interface{}) []interface{} {
-    v := reflect.ValueOf(m)
-    if v.Kind() != reflect.Ptr || v.IsNil() {
-        return nil
+// Transaction runs fn inside a database transaction. The transaction is
+// committed if fn returns nil and rolled back if fn returns an error or
+// panics; a panic is re-raised after the rollback.
+func (c *Client) Transaction(ctx context.Context, fn func(tx *TxClient) error, opts ...TxOption) error {
+    s, err := c.connect()
+    if err != nil {
+        return fmt.Errorf("connect: %w", err)
     }
-    v = v.Elem()
-    if v.Kind() != reflect.Struct {
-        return nil
+    var txOpts sql.TxOptions
+    for _, opt := range opts {
+        opt(&txOpts)
     }
+    return s.Transaction(ctx, func(tx *runtime.Session) error {
+        return fn(&TxClient{session: tx, now: c.now})
+    }, &txOpts)
+}
+
+// Transaction runs fn inside a savepoint of the transaction, so that an error
+// from fn only rolls back the work done by fn.
+func (t *TxClient) Transaction(ctx context.Context, fn func(tx *TxClient) error) error {
+    return t.session.Transaction(ctx, func(tx *runtime.Session) error {
+        return fn(&TxClient{session: tx, now: t.now})
+    }, nil)
+}
 
-    var dest []interface{}
-    for i := 0; i < v.NumField(); i++ {
-        field := v.Field(i)
-        fieldType := field.Type()
+{{- range .Entities }}
 
-        switch fieldType {
-        case reflect.TypeOf(time.Time{}):
-            // Use a TimeOrZero placeholder
-            var tmp TimeOrZero
-            dest = append(dest, &tmp)
+// {{ unexport .Name }}Columns lists the {{ .Name }} columns in struct field order.
+var {{ unexport .Name }}Columns = []string{ {{- range $i,$f := .Fields }}{{if $i}}, {{end}}"{{ $f.Column }}"{{- end }} }
 
-        case reflect.TypeOf(""):
-            // Use a sql.NullString placeholder instead of a bare string
-            var tmp sql.NullString
-            dest = append(dest, &tmp)
+// {{ unexport .Name }}Nullable holds the {{ .Name }} columns that may be NULL.
+var {{ unexport .Name }}Nullable = map[string]bool{ {{- range .Fields }}{{ if not .NotNull }}"{{ .Column }}": true, {{ end }}{{ end }} }
 
+// scanDest returns scan destinations for the given {{ .Name }} columns.
+// NULL values scan into pointer or sql.Null fields of optional columns.
+func (m *{{ .Name }}) scanDest(cols []string) []interface{} {
+    dest := make([]interface{}, len(cols))
+    for i, col := range cols {
+        switch col {
+    {{- range .Fields }}
+        case "{{ .Column }}":
+            dest[i] = &m.{{ export .Name }}
+    {{- end }}
         default:
-            if field.CanAddr() {
-                dest = append(dest, field.Addr().Interface())
-            } else {
-                var dummy interface{}
-                dest = append(dest, &dummy)
-            }
+            dest[i] = new(interface{})
         }
     }
     return dest
 }
 
-{{- range .Entities }}
-
 // {{ .Name }}Service provides DB operations for the {{ .Name }} model.
 type {{ .Name }}Service struct {
-    db *sql.DB
+    db  Executor
+    now func() time.Time
+}
+
+// {{ .Name }}Service returns a {{ .Name }} service that runs inside the transaction.
+func (t *TxClient) {{ .Name }}Service() *{{ .Name }}Service {
+    return &{{ .Name }}Service{db: t.session, now: t.now}
 }
 
 // {{ .Name }}Service returns a new service for {{ .Name }}.
 func (c *Client) {{ .Name }}Service() (*{{ .Name }}Service, error) {
-    db, err := c.connect()
+    s, err := c.connect()
     if err != nil {
         return nil, fmt.Errorf("connect: %w", err)
     }
-    return &{{ .Name }}Service{db: db}, nil
+    return &{{ .Name }}Service{db: s, now: c.now}, nil
+}
+{{- $ent := . }}
+
+// {{ .Name }}WhereUnique selects a single {{ .Name }} by its primary key or a unique constraint.
+// At least one field must be set; set fields are combined with AND.
+type {{ .Name }}WhereUnique struct {
+{{- range uniqueFields . }}
+    {{ export .Name }} *{{ .Type }}
+{{- end }}
+{{- range compoundKeys . }}
+    {{ compoundName .Fields }} *{{ $ent.Name }}{{ compoundName .Fields }}Key
+{{- end }}
+}
+{{- range compoundKeys . }}
+
+// {{ $ent.Name }}{{ compoundName .Fields }}Key is the compound key ({{ join .Fields ", " }}) of {{ $ent.Name }}.
+type {{ $ent.Name }}{{ compoundName .Fields }}Key struct {
+{{- range .Fields }}
+    {{ export . }} {{ (fieldOf $ent .).Type }}
+{{- end }}
+}
+{{- end }}
+
+// toMap converts the unique selector into column filters.
+func (w {{ .Name }}WhereUnique) toMap() (map[string]interface{}, error) {
+    where := map[string]interface{}{}
+{{- range uniqueFields . }}
+    if w.{{ export .Name }} != nil {
+        where["{{ .Column }}"] = *w.{{ export .Name }}
+    }
+{{- end }}
+{{- range compoundKeys . }}
+    if w.{{ compoundName .Fields }} != nil {
+    {{- $key := compoundName .Fields }}
+    {{- range .Fields }}
+        where["{{ (fieldOf $ent .).Column }}"] = w.{{ $key }}.{{ export . }}
+    {{- end }}
+    }
+{{- end }}
+    if len(where) == 0 {
+        return nil, fmt.Errorf("{{ .Name }}WhereUnique: no unique field set")
+    }
+    return where, nil
+}
+
+// conflictTarget returns the columns of the unique key selected by w, which
+// must set exactly one field, for use as an ON CONFLICT target.
+func (w {{ .Name }}WhereUnique) conflictTarget() ([]string, error) {
+    var target []string
+    n := 0
+{{- range uniqueFields . }}
+    if w.{{ export .Name }} != nil {
+        target = []string{"{{ .Column }}"}
+        n++
+    }
+{{- end }}
+{{- range compoundKeys . }}
+    if w.{{ compoundName .Fields }} != nil {
+        target = []string{ {{- range $i, $f := .Fields }}{{ if $i }}, {{ end }}"{{ (fieldOf $ent $f).Column }}"{{ end }} }
+        n++
+    }
+{{- end }}
+    if n != 1 {
+        return nil, fmt.Errorf("{{ .Name }}WhereUnique: exactly one unique key must be set, got %d", n)
+    }
+    return target, nil
+}
+
+// {{ .Name }}Where filters {{ .Name }} records. Set fields are combined with AND;
+// AND, OR and NOT nest further {{ .Name }}Where values.
+type {{ .Name }}Where struct {
+{{- range .Fields }}
+{{- $filter := filterType . }}
+{{- if $filter }}
+    {{ export .Name }} {{ $filter }}
+{{- end }}
+{{- end }}
+{{- range .Relations }}
+    {{ export .Name }} {{ if .List }}ListRelationFilter{{ else }}RelationFilter{{ end }}[{{ .Type }}Where]
+{{- end }}
+
+    AND []{{ .Name }}Where // every element must match
+    OR  []{{ .Name }}Where // at least one element must match
+    NOT []{{ .Name }}Where // no element may match
+}
+
+// build appends the conditions of w to b.
+func (w {{ .Name }}Where) build(b *whereBuilder) {
+    t := b.qualifier(quoteIdent("{{ .Table }}"))
+{{- range .Fields }}
+{{- if filterType . }}
+    w.{{ export .Name }}.build(b, t+"."+quoteIdent("{{ .Column }}"))
+{{- end }}
+{{- end }}
+{{- $ent := . }}
+{{- range .Relations }}
+{{- $join := relationJoin $.Entities $ent . }}
+{{- if .JoinTableName }}
+    w.{{ export .Name }}.build(b, t, link{table: "{{ (entity $.Entities .Type).Table }}", local: []string{"{{ index $join.LocalColumns 0 }}"}, remote: []string{"{{ .JoinColumn }}"},
+        through: "{{ .JoinTableName }}", throughKey: "{{ .JoinTarget }}", targetKey: "{{ (primaryKey (entity $.Entities .Type)).Column }}"})
+{{- else }}
+    w.{{ export .Name }}.build(b, t, link{table: "{{ (entity $.Entities .Type).Table }}", local: []string{ {{- range $i, $c := $join.LocalColumns }}{{ if $i }}, {{ end }}"{{ $c }}"{{ end }} }, remote: []string{ {{- range $i, $c := $join.RemoteColumns }}{{ if $i }}, {{ end }}"{{ $c }}"{{ end }} }})
+{{- end }}
+{{- end }}
+    allOf(b, w.AND)
+    anyOf(b, w.OR)
+    noneOf(b, w.NOT)
+}
+
+{{- $ent := . }}
+
+// {{ .Name }}UpdateInput holds the changes of an update. Only fields with an
+// operation set are written.{{ if nestedWrites $.Entities . }} Relation fields hold nested writes of related
+// records.{{ end }}
+type {{ .Name }}UpdateInput struct {
+{{- range .Fields }}
+{{- if not .PrimaryKey }}
+    {{ export .Name }} {{ updateType . }}
+{{- end }}
+{{- end }}
+{{- range .Relations }}
+{{- if writeKind $.Entities $ent . }}
+    {{ export .Name }} {{ if .List }}ListRelationUpdate{{ else }}RelationUpdate{{ end }}[{{ .Type }}CreateInput, {{ .Type }}WhereUnique]
+{{- end }}
+{{- end }}
+}
+{{- if nestedWrites $.Entities . }}
+
+// nested reports whether in has nested writes.
+func (in {{ .Name }}UpdateInput) nested() bool {
+{{- range .Relations }}
+{{- if writeKind $.Entities $ent . }}
+    if in.{{ export .Name }}.set() {
+        return true
+    }
+{{- end }}
+{{- end }}
+    return false
+}
+{{- end }}
+
+// build appends the assignments of in to b.{{ if hasUpdatedAt . }} Unless in sets them, @updatedAt
+// fields are set to now when anything else is written.{{ end }}
+func (in {{ .Name }}UpdateInput) build(b *whereBuilder, now time.Time) error {
+{{- range .Fields }}
+{{- if not .PrimaryKey }}
+    if err := in.{{ export .Name }}.build(b, "{{ .Column }}", {{ not .NotNull }}); err != nil {
+        return err
+    }
+{{- end }}
+{{- end }}
+{{- if hasUpdatedAt . }}
+    if len(b.conds) > 0 {
+    {{- range .Fields }}
+    {{- if and .UpdatedAt (not .PrimaryKey) }}
+        if in.{{ export .Name }}.Set == nil && !in.{{ export .Name }}.SetNull {
+            b.add(quoteIdent("{{ .Column }}") + " = " + b.arg(now))
+        }
+    {{- end }}
+    {{- end }}
+    }
+{{- end }}
+    return nil
+}
+
+// {{ .Name }}Field names a {{ .Name }} column for ordering.
+type {{ .Name }}Field string
+
+const (
+{{- range .Fields }}
+    {{ $ent.Name }}Field{{ export .Name }} {{ $ent.Name }}Field = "{{ .Column }}"
+{{- end }}
+)
+{{- if hasListRelation . }}
+
+// {{ .Name }}Relation names a {{ .Name }} list relation whose record count can be ordered by.
+type {{ .Name }}Relation string
+
+const (
+{{- range .Relations }}
+{{- if .List }}
+    {{ $ent.Name }}Relation{{ export .Name }} {{ $ent.Name }}Relation = "{{ .Name }}"
+{{- end }}
+{{- end }}
+)
+{{- end }}
+
+// {{ .Name }}OrderBy orders {{ .Name }} records by exactly one of a column,
+{{- if .Relations }} a field of a
+// singular relation or the record count of a list relation.
+{{- else }} with Dir
+// and Nulls.
+{{- end }}
+type {{ .Name }}OrderBy struct {
+    Field {{ .Name }}Field
+{{- range .Relations }}
+{{- if not .List }}
+    {{ export .Name }} *{{ .Type }}OrderBy // ordered by the related record's field, with its Dir and Nulls
+{{- end }}
+{{- end }}
+{{- if hasListRelation . }}
+    Count {{ .Name }}Relation
+{{- end }}
+    Dir   SortOrder
+    Nulls NullsOrder
+}
+
+// expr returns the SQL expression o orders by, with columns of {{ .Name }} qualified with t.
+func (o {{ .Name }}OrderBy) expr(t string, depth int) (string, SortOrder, NullsOrder, error) {
+    set := 0
+    if o.Field != "" {
+        set++
+    }
+{{- range .Relations }}
+{{- if not .List }}
+    if o.{{ export .Name }} != nil {
+        set++
+    }
+{{- end }}
+{{- end }}
+{{- if hasListRelation . }}
+    if o.Count != "" {
+        set++
+    }
+{{- end }}
+    if set != 1 {
+        return "", 0, 0, fmt.Errorf("{{ .Name }}OrderBy: exactly one ordering must be set, got %d", set)
+    }
+{{- range .Relations }}
+{{- $join := relationJoin $.Entities $ent . }}
+{{- if not .List }}
+    if o.{{ export .Name }} != nil {
+        return orderByRelation(t, depth, link{table: "{{ (entity $.Entities .Type).Table }}", local: []string{ {{- range $i, $c := $join.LocalColumns }}{{ if $i }}, {{ end }}"{{ $c }}"{{ end }} }, remote: []string{ {{- range $i, $c := $join.RemoteColumns }}{{ if $i }}, {{ end }}"{{ $c }}"{{ end }} }}, o.{{ export .Name }}.expr)
+    }
+{{- end }}
+{{- end }}
+{{- if hasListRelation . }}
+    switch o.Count {
+{{- range .Relations }}
+{{- $join := relationJoin $.Entities $ent . }}
+{{- if .List }}
+    case {{ $ent.Name }}Relation{{ export .Name }}:
+{{- if .JoinTableName }}
+        return orderByCount(t, depth, link{table: "{{ .JoinTableName }}", local: []string{"{{ index $join.LocalColumns 0 }}"}, remote: []string{"{{ .JoinColumn }}"}}), o.Dir, o.Nulls, nil
+{{- else }}
+        return orderByCount(t, depth, link{table: "{{ (entity $.Entities .Type).Table }}", local: []string{ {{- range $i, $c := $join.LocalColumns }}{{ if $i }}, {{ end }}"{{ $c }}"{{ end }} }, remote: []string{ {{- range $i, $c := $join.RemoteColumns }}{{ if $i }}, {{ end }}"{{ $c }}"{{ end }} }}), o.Dir, o.Nulls, nil
+{{- end }}
+{{- end }}
+{{- end }}
+    case "":
+    default:
+        return "", 0, 0, fmt.Errorf("{{ .Name }}OrderBy: unknown relation %q", o.Count)
+    }
+{{- end }}
+    col, err := column("{{ .Name }}", {{ unexport .Name }}Columns, string(o.Field))
+    if err != nil {
+        return "", 0, 0, err
+    }
+    return t + "." + col, o.Dir, o.Nulls, nil
+}
+
+// orderClause returns the ORDER BY clause for orderBy, or "" if it is empty.
+func (svc *{{ .Name }}Service) orderClause(orderBy []{{ .Name }}OrderBy) (string, error) {
+    if len(orderBy) == 0 {
+        return "", nil
+    }
+    terms := make([]string, len(orderBy))
+    for i, o := range orderBy {
+        expr, dir, nulls, err := o.expr(quoteIdent("{{ .Table }}"), 0)
+        if err != nil {
+            return "", err
+        }
+        terms[i] = orderTerm(expr, dir, nulls)
+    }
+    return " ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+// {{ .Name }}QueryOption customizes the records returned by FindUnique, FindFirst and FindMany.
+type {{ .Name }}QueryOption interface {
+    apply{{ .Name }}Query(q *{{ unexport .Name }}Query)
+}
+
+// {{ unexport .Name }}Query holds the options of a {{ .Name }} read.
+type {{ unexport .Name }}Query struct {
+    columns []string
+    cursor  {{ .Name }}Cursor
+    keyset  bool
+{{- if .Relations }}
+    include {{ .Name }}Include
+{{- end }}
+}
+
+func new{{ .Name }}Query(opts []{{ .Name }}QueryOption) {{ unexport .Name }}Query {
+    q := {{ unexport .Name }}Query{columns: {{ unexport .Name }}Columns}
+    for _, opt := range opts {
+        opt.apply{{ .Name }}Query(&q)
+    }
+    return q
+}
+
+// selectColumns returns the columns to fetch, adding the keys that included relations are loaded by.
+func (q {{ unexport .Name }}Query) selectColumns() []string {
+    cols := q.columns
+{{- range .Relations }}
+    if q.include.{{ export .Name }} {
+        cols = withColumns(cols{{ range (relationJoin $.Entities $ent .).LocalColumns }}, "{{ . }}"{{ end }})
+    }
+{{- end }}
+    return cols
+}
+{{- if .Relations }}
+
+// {{ .Name }}Include selects the relations loaded with each {{ .Name }}.
+type {{ .Name }}Include struct {
+{{- range .Relations }}
+    {{ export .Name }} bool
+{{- end }}
+}
+
+func (i {{ .Name }}Include) apply{{ .Name }}Query(q *{{ unexport .Name }}Query) {
+{{- range .Relations }}
+    q.include.{{ export .Name }} = q.include.{{ export .Name }} || i.{{ export .Name }}
+{{- end }}
+}
+{{- end }}
+
+// {{ .Name }}Select limits a read to the chosen fields{{ if .Relations }} and relations{{ end }}.
+// The primary key is always fetched; unselected fields keep their zero value.
+type {{ .Name }}Select struct {
+{{- range .Fields }}
+    {{ export .Name }} bool
+{{- end }}
+{{- range .Relations }}
+    {{ export .Name }} bool
+{{- end }}
 }
 
-// FindUnique retrieves a single {{ .Name }} by unique filter.
-func (svc *{{ .Name }}Service) FindUnique(ctx context.Context, where map[string]interface{}) (*{{ .Name }}, error) {
-    whereClause, args := buildWhere(where)
-    cols := []string{ {{- range $i,$f := .Fields }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-    query := fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 1", strings.Join(cols, ", "), "{{lower .Name}}", whereClause)
+func (s {{ .Name }}Select) apply{{ .Name }}Query(q *{{ unexport .Name }}Query) {
+    cols := []string{ {{- range $i, $f := primaryKeys . }}{{ if $i }}, {{ end }}"{{ $f.Column }}"{{ end }} }
+{{- range .Fields }}
+{{- if not (isPrimaryKey $ent .) }}
+    if s.{{ export .Name }} {
+        cols = append(cols, "{{ .Column }}")
+    }
+{{- end }}
+{{- end }}
+    q.columns = cols
+{{- range .Relations }}
+    q.include.{{ export .Name }} = q.include.{{ export .Name }} || s.{{ export .Name }}
+{{- end }}
+}
+
+// {{ .Name }}Cursor is an opaque token marking a position in a FindMany ordering,
+// as returned by FindPage and CursorOf. Passed as an option to FindMany or
+// FindPage, it continues after that position using a keyset predicate
+// instead of OFFSET. The ordering must be the one the cursor was made with.
+type {{ .Name }}Cursor string
+
+func (c {{ .Name }}Cursor) apply{{ .Name }}Query(q *{{ unexport .Name }}Query) {
+    q.cursor = c
+}
+
+// value returns the value of column col of m, or nil if it is NULL.
+func (m *{{ .Name }}) value(col string) interface{} {
+    switch col {
+{{- range .Fields }}
+    case "{{ .Column }}":
+    {{- with nullExpr $.OptionalTypes . "m" }}
+        if {{ . }} {
+            return nil
+        }
+    {{- end }}
+        return {{ valueExpr $.OptionalTypes . "m" }}
+{{- end }}
+    }
+    return nil
+}
+
+// orderKeys returns the columns of a keyset ordering by orderBy, followed by
+// the primary key to make the order total.
+func (svc *{{ .Name }}Service) orderKeys(orderBy []{{ .Name }}OrderBy) ([]orderKey, error) {
+    var keys []orderKey
+    seen := map[string]bool{}
+    for _, o := range orderBy {
+        if _, _, _, err := o.expr(quoteIdent("{{ .Table }}"), 0); err != nil {
+            return nil, err
+        }
+        if o.Field == "" {
+            return nil, fmt.Errorf("{{ .Name }}: cursor pagination can only order by fields")
+        }
+        col, err := columnName("{{ .Name }}", {{ unexport .Name }}Columns, string(o.Field))
+        if err != nil {
+            return nil, err
+        }
+        if !seen[col] {
+            seen[col] = true
+            keys = append(keys, orderKey{col: col, expr: quoteIdent("{{ .Table }}") + "." + quoteIdent(col), dir: o.Dir, nulls: o.Nulls, nullable: {{ unexport .Name }}Nullable[col]})
+        }
+    }
+{{- range primaryKeys . }}
+    if !seen["{{ .Column }}"] {
+        keys = append(keys, orderKey{col: "{{ .Column }}", expr: quoteIdent("{{ $ent.Table }}") + "." + quoteIdent("{{ .Column }}")})
+    }
+{{- end }}
+    return keys, nil
+}
+
+// CursorOf returns the cursor positioned after m in the ordering orderBy.
+func (svc *{{ .Name }}Service) CursorOf(m *{{ .Name }}, orderBy []{{ .Name }}OrderBy) ({{ .Name }}Cursor, error) {
+    keys, err := svc.orderKeys(orderBy)
+    if err != nil {
+        return "", err
+    }
+    vals := make([]interface{}, len(keys))
+    for i, k := range keys {
+        vals[i] = m.value(k.col)
+    }
+    token, err := encodeCursor(keys, vals)
+    return {{ .Name }}Cursor(token), err
+}
+
+// FindUnique retrieves a single {{ .Name }} by primary key or unique field.
+func (svc *{{ .Name }}Service) FindUnique(ctx context.Context, where {{ .Name }}WhereUnique, opts ...{{ .Name }}QueryOption) (*{{ .Name }}, error) {
+    whereMap, err := where.toMap()
+    if err != nil {
+        return nil, err
+    }
+    q := new{{ .Name }}Query(opts)
+    whereClause, args := buildWhere(whereMap)
+    cols := q.selectColumns()
+    query := fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 1", strings.Join(quoteIdents(cols), ", "), quoteIdent("{{ .Table }}"), whereClause)
     row := svc.db.QueryRowContext(ctx, query, args...)
     var m {{ .Name }}
-    dest := scanDest(&m)
-    dest = dest[:len(cols)] // Ensure we only scan expected columns
-    if err := row.Scan(dest...); err != nil {
+    if err := row.Scan(m.scanDest(cols)...); err != nil {
         if err == sql.ErrNoRows {
             return nil, nil
         }
         return nil, err
     }
-    structVal := reflect.ValueOf(&m).Elem()
-    for i := 0; i < len(cols); i++ {
-        switch placeholder := dest[i].(type) {
-        case *sql.NullString:
-            if placeholder.Valid {
-                structVal.Field(i).SetString(placeholder.String)
-            } else {
-                structVal.Field(i).SetString("")
-            }
-        case *TimeOrZero:
-            t := time.Time(*placeholder)
-            structVal.Field(i).Set(reflect.ValueOf(t))
-        }
-    }
-    // Load one‐level relations
-    {{- $ent := . }}
-    {{- range .Relations }}
-        {{- if .JoinTableName }}
-            // load many-to-many {{ .Type }} via join table {{ .JoinTableName }}
-            {
-            colsRel := []string{ {{- range $i,$f := call $.EntitiesMap .Type }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-            rows{{ .Name }}, err := svc.db.QueryContext(ctx,
-                fmt.Sprintf(
-                    "SELECT %s FROM %s t JOIN %s jt ON t.id = jt.%s_id WHERE jt.%s_id = $1",
-                    strings.Join(colsRel, ", "),
-                    "{{ lower .Type }}",
-                    "{{ lower .JoinTableName }}",
-                    "{{ lower .Type }}",
-                    "{{ lower $ent.Name }}",
-                ),
-                m.Id,
-            )
-            if err == nil {
-                defer rows{{ .Name }}.Close()
-                for rows{{ .Name }}.Next() {
-                    var related {{ .Type }}
-                    destRel := scanDest(&related)
-                    destRel = destRel[:len(colsRel)]
-                    if err := rows{{ .Name }}.Scan(destRel...); err == nil {
-                        // Copy scanned values into struct fields
-                        structValRel := reflect.ValueOf(&related).Elem()
-                        for i := 0; i < len(colsRel); i++ {
-                            switch placeholder := destRel[i].(type) {
-                            case *sql.NullString:
-                                if placeholder.Valid {
-                                    structValRel.Field(i).SetString(placeholder.String)
-                                } else {
-                                    structValRel.Field(i).SetString("")
-                                }
-                            case *TimeOrZero:
-                                t := time.Time(*placeholder)
-                                structValRel.Field(i).Set(reflect.ValueOf(t))
-                            }
-                        }
-                        m.{{ export .Name }} = append(m.{{ export .Name }}, related)
-                    }
-                }
-            }
-            }
-        {{- else }}
-            // one-to-many load of {{ .Type }}
-            {
-            colsRel := []string{ {{- range $i,$f := call $.EntitiesMap .Type }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-            rows{{ .Name }}, err := svc.db.QueryContext(ctx,
-                fmt.Sprintf("SELECT %s FROM %s WHERE %sid = $1",
-                    strings.Join(colsRel, ", "),
-                    "{{ lower .Type }}",
-                    "{{ lower $ent.Name }}",
-                ),
-                m.Id,
-            )
-            if err == nil {
-                defer rows{{ .Name }}.Close()
-                for rows{{ .Name }}.Next() {
-                    var related {{ .Type }}
-                    destRel := scanDest(&related)
-                    destRel = destRel[:len(colsRel)]
-                    if err := rows{{ .Name }}.Scan(destRel...); err == nil {
-                        // Copy scanned values into struct fields
-                        structValRel := reflect.ValueOf(&related).Elem()
-                        for i := 0; i < len(colsRel); i++ {
-                            switch placeholder := destRel[i].(type) {
-                            case *sql.NullString:
-                                if placeholder.Valid {
-                                    structValRel.Field(i).SetString(placeholder.String)
-                                } else {
-                                    structValRel.Field(i).SetString("")
-                                }
-                            case *TimeOrZero:
-                                t := time.Time(*placeholder)
-                                structValRel.Field(i).Set(reflect.ValueOf(t))
-                            }
-                        }
-                        m.{{ export .Name }} = append(m.{{ export .Name }}, related)
-                    }
-                }
-            }
-            }
-        {{- end }}
-    {{- end }}
+{{- if .Relations }}
+    if err := svc.loadRelations(ctx, []*{{ .Name }}{&m}, q.include); err != nil {
+        return nil, err
+    }
+{{- end }}
     return &m, nil
 }
 
 // FindUniqueOrThrow retrieves a single {{ .Name }} or returns an error if not found.
-func (svc *{{ .Name }}Service) FindUniqueOrThrow(ctx context.Context, where map[string]interface{}) (*{{ .Name }}, error) {
-    rec, err := svc.FindUnique(ctx, where)
+func (svc *{{ .Name }}Service) FindUniqueOrThrow(ctx context.Context, where {{ .Name }}WhereUnique, opts ...{{ .Name }}QueryOption) (*{{ .Name }}, error) {
+    rec, err := svc.FindUnique(ctx, where, opts...)
     if err != nil {
         return nil, err
     }
@@ -346,129 +791,31 @@ func (svc *{{ .Name }}Service) FindUniqueOrThrow(ctx context.Context, where map[
 }
 
 // FindFirst retrieves a single {{ .Name }} matching filters, or nil if none.
-func (svc *{{ .Name }}Service) FindFirst(ctx context.Context, where map[string]interface{}) (*{{ .Name }}, error) {
-    whereClause, args := buildWhere(where)
-    cols := []string{ {{- range $i,$f := .Fields }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-    query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(cols, ", "), "{{lower .Name}}")
-    if whereClause != "" {
-        query += " WHERE " + whereClause
-    }
-    row := svc.db.QueryRowContext(ctx, query, args...)
+func (svc *{{ .Name }}Service) FindFirst(ctx context.Context, where {{ .Name }}Where, opts ...{{ .Name }}QueryOption) (*{{ .Name }}, error) {
+    q := new{{ .Name }}Query(opts)
+    b := &whereBuilder{}
+    where.build(b)
+    cols := q.selectColumns()
+    query := fmt.Sprintf("SELECT %s FROM %s%s LIMIT 1", strings.Join(quoteIdents(cols), ", "), quoteIdent("{{ .Table }}"), b.where())
+    row := svc.db.QueryRowContext(ctx, query, b.args...)
     var m {{ .Name }}
-    dest := scanDest(&m)
-    dest = dest[:len(cols)] // Ensure we only scan expected columns
-    if err := row.Scan(dest...); err != nil {
+    if err := row.Scan(m.scanDest(cols)...); err != nil {
         if err == sql.ErrNoRows {
             return nil, nil
         }
         return nil, err
     }
-    structVal := reflect.ValueOf(&m).Elem()
-    for i := 0; i < len(cols); i++ {
-        switch placeholder := dest[i].(type) {
-        case *sql.NullString:
-            if placeholder.Valid {
-                structVal.Field(i).SetString(placeholder.String)
-            } else {
-                structVal.Field(i).SetString("")
-            }
-        case *TimeOrZero:
-            t := time.Time(*placeholder)
-            structVal.Field(i).Set(reflect.ValueOf(t))
-        }
-    }
-    // Load one‐level relations
-    {{- $ent := . }}
-    {{- range .Relations }}
-        {{- if .JoinTableName }}
-            // load many-to-many {{ .Type }} via join table {{ .JoinTableName }}
-            {
-            colsRel := []string{ {{- range $i,$f := call $.EntitiesMap .Type }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-            rows{{ .Name }}, err := svc.db.QueryContext(ctx,
-                fmt.Sprintf(
-                    "SELECT %s FROM %s t JOIN %s jt ON t.id = jt.%s_id WHERE jt.%s_id = $1",
-                    strings.Join(colsRel, ", "),
-                    "{{ lower .Type }}",
-                    "{{ lower .JoinTableName }}",
-                    "{{ lower .Type }}",
-                    "{{ lower $ent.Name }}",
-                ),
-                m.Id,
-            )
-            if err == nil {
-                defer rows{{ .Name }}.Close()
-                for rows{{ .Name }}.Next() {
-                    var related {{ .Type }}
-                    destRel := scanDest(&related)
-                    destRel = destRel[:len(colsRel)]
-                    if err := rows{{ .Name }}.Scan(destRel...); err == nil {
-                        // Copy scanned values into struct fields
-                        structValRel := reflect.ValueOf(&related).Elem()
-                        for i := 0; i < len(colsRel); i++ {
-                            switch placeholder := destRel[i].(type) {
-                            case *sql.NullString:
-                                if placeholder.Valid {
-                                    structValRel.Field(i).SetString(placeholder.String)
-                                } else {
-                                    structValRel.Field(i).SetString("")
-                                }
-                            case *TimeOrZero:
-                                t := time.Time(*placeholder)
-                                structValRel.Field(i).Set(reflect.ValueOf(t))
-                            }
-                        }
-                        m.{{ export .Name }} = append(m.{{ export .Name }}, related)
-                    }
-                }
-            }
-            }
-        {{- else }}
-            // one-to-many load of {{ .Type }}
-            {
-            colsRel := []string{ {{- range $i,$f := call $.EntitiesMap .Type }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-            rows{{ .Name }}, err := svc.db.QueryContext(ctx,
-                fmt.Sprintf("SELECT %s FROM %s WHERE %sid = $1",
-                    strings.Join(colsRel, ", "),
-                    "{{ lower .Type }}",
-                    "{{ lower $ent.Name }}",
-                ),
-                m.Id,
-            )
-            if err == nil {
-                defer rows{{ .Name }}.Close()
-                for rows{{ .Name }}.Next() {
-                    var related {{ .Type }}
-                    destRel := scanDest(&related)
-                    destRel = destRel[:len(colsRel)]
-                    if err := rows{{ .Name }}.Scan(destRel...); err == nil {
-                        // Copy scanned values into struct fields
-                        structValRel := reflect.ValueOf(&related).Elem()
-                        for i := 0; i < len(colsRel); i++ {
-                            switch placeholder := destRel[i].(type) {
-                            case *sql.NullString:
-                                if placeholder.Valid {
-                                    structValRel.Field(i).SetString(placeholder.String)
-                                } else {
-                                    structValRel.Field(i).SetString("")
-                                }
-                            case *TimeOrZero:
-                                t := time.Time(*placeholder)
-                                structValRel.Field(i).Set(reflect.ValueOf(t))
-                            }
-                        }
-                        m.{{ export .Name }} = append(m.{{ export .Name }}, related)
-                    }
-                }
-            }
-            }
-        {{- end }}
-    {{- end }}
+{{- if .Relations }}
+    if err := svc.loadRelations(ctx, []*{{ .Name }}{&m}, q.include); err != nil {
+        return nil, err
+    }
+{{- end }}
     return &m, nil
 }
 
 // FindFirstOrThrow retrieves the first {{ .Name }} or errors if none.
-func (svc *{{ .Name }}Service) FindFirstOrThrow(ctx context.Context, where map[string]interface{}) (*{{ .Name }}, error) {
-    rec, err := svc.FindFirst(ctx, where)
+func (svc *{{ .Name }}Service) FindFirstOrThrow(ctx context.Context, where {{ .Name }}Where, opts ...{{ .Name }}QueryOption) (*{{ .Name }}, error) {
+    rec, err := svc.FindFirst(ctx, where, opts...)
     if err != nil {
         return nil, err
     }
@@ -479,276 +826,772 @@ func (svc *{{ .Name }}Service) FindFirstOrThrow(ctx context.Context, where map[s
 }
 
 // FindMany retrieves multiple {{ .Name }} records matching filters.
-func (svc *{{ .Name }}Service) FindMany(ctx context.Context, where map[string]interface{}, orderBy []string, skip, take int) ([]*{{ .Name }}, error) {
-    whereClause, args := buildWhere(where)
-    {
-    cols := []string{ {{- range $i,$f := .Fields }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-    query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(cols, ", "), "{{lower .Name}}")
-    if whereClause != "" {
-        query += " WHERE " + whereClause
+func (svc *{{ .Name }}Service) FindMany(ctx context.Context, where {{ .Name }}Where, orderBy []{{ .Name }}OrderBy, skip, take int, opts ...{{ .Name }}QueryOption) ([]*{{ .Name }}, error) {
+    result, _, err := svc.findMany(ctx, where, orderBy, skip, take, new{{ .Name }}Query(opts))
+    return result, err
+}
+
+// FindPage retrieves up to take {{ .Name }} records after the cursor, or from
+// the start if after is empty, and returns the cursor of the next page, or ""
+// if this is the last page.
+func (svc *{{ .Name }}Service) FindPage(ctx context.Context, where {{ .Name }}Where, orderBy []{{ .Name }}OrderBy, take int, after {{ .Name }}Cursor, opts ...{{ .Name }}QueryOption) ([]*{{ .Name }}, {{ .Name }}Cursor, error) {
+    if take <= 0 {
+        return nil, "", fmt.Errorf("{{ .Name }}: FindPage needs a positive take")
+    }
+    q := new{{ .Name }}Query(opts)
+    q.cursor, q.keyset = after, true
+    // One extra record tells whether another page follows.
+    result, keys, err := svc.findMany(ctx, where, orderBy, 0, take+1, q)
+    if err != nil || len(result) <= take {
+        return result, "", err
     }
-    if len(orderBy) > 0 {
-        query += " ORDER BY " + strings.Join(orderBy, ", ")
+    result = result[:take]
+    last := result[take-1]
+    vals := make([]interface{}, len(keys))
+    for i, k := range keys {
+        vals[i] = last.value(k.col)
+    }
+    next, err := encodeCursor(keys, vals)
+    return result, {{ .Name }}Cursor(next), err
+}
+
+// FindManyIter is like FindMany but scans the records lazily as the iterator
+// is advanced, for result sets too large to hold in memory. The rows are
+// closed when iteration ends or is stopped early. Relations are not loaded.
+func (svc *{{ .Name }}Service) FindManyIter(ctx context.Context, where {{ .Name }}Where, orderBy []{{ .Name }}OrderBy, skip, take int, opts ...{{ .Name }}QueryOption) iter.Seq2[*{{ .Name }}, error] {
+    return func(yield func(*{{ .Name }}, error) bool) {
+        q := new{{ .Name }}Query(opts)
+{{- if .Relations }}
+        if q.include != ({{ .Name }}Include{}) {
+            yield(nil, fmt.Errorf("{{ .Name }}: FindManyIter does not load relations"))
+            return
+        }
+{{- end }}
+        query, args, cols, _, err := svc.selectQuery(where, orderBy, skip, take, q)
+        if err != nil {
+            yield(nil, err)
+            return
+        }
+        rows, err := svc.db.QueryContext(ctx, query, args...)
+        if err != nil {
+            yield(nil, err)
+            return
+        }
+        defer rows.Close()
+        for rows.Next() {
+            m := new({{ .Name }})
+            if err := rows.Scan(m.scanDest(cols)...); err != nil {
+                yield(nil, err)
+                return
+            }
+            if !yield(m, nil) {
+                return
+            }
+        }
+        if err := rows.Err(); err != nil {
+            yield(nil, err)
+        }
     }
+}
+
+// findMany runs a FindMany query and returns the keyset ordering it used, if any.
+func (svc *{{ .Name }}Service) findMany(ctx context.Context, where {{ .Name }}Where, orderBy []{{ .Name }}OrderBy, skip, take int, q {{ unexport .Name }}Query) ([]*{{ .Name }}, []orderKey, error) {
+    query, args, cols, keys, err := svc.selectQuery(where, orderBy, skip, take, q)
+    if err != nil {
+        return nil, nil, err
+    }
+    rows, err := svc.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, nil, err
+    }
+    defer rows.Close()
+    var result []*{{ .Name }}
+    for rows.Next() {
+        var m {{ .Name }}
+        if err := rows.Scan(m.scanDest(cols)...); err != nil {
+            return nil, nil, err
+        }
+        result = append(result, &m)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, nil, err
+    }
+{{- if .Relations }}
+    rows.Close()
+    // Relations are loaded once the result set is closed, with one query
+    // per included relation for the whole page.
+    if err := svc.loadRelations(ctx, result, q.include); err != nil {
+        return nil, nil, err
+    }
+{{- end }}
+    return result, keys, nil
+}
+
+// selectQuery builds the SELECT statement of a FindMany call and returns it
+// with its arguments, the columns it fetches and its keyset ordering, if any.
+func (svc *{{ .Name }}Service) selectQuery(where {{ .Name }}Where, orderBy []{{ .Name }}OrderBy, skip, take int, q {{ unexport .Name }}Query) (string, []interface{}, []string, []orderKey, error) {
+    b := &whereBuilder{}
+    where.build(b)
+    cols := q.selectColumns()
+    var keys []orderKey
+    var order string
+    if q.keyset || q.cursor != "" {
+        var err error
+        if keys, err = svc.orderKeys(orderBy); err != nil {
+            return "", nil, nil, nil, err
+        }
+        if q.cursor != "" {
+            vals, err := decodeCursor(string(q.cursor), keys)
+            if err != nil {
+                return "", nil, nil, nil, err
+            }
+            after(b, keys, vals)
+        }
+        terms := make([]string, len(keys))
+        for i, k := range keys {
+            cols = withColumns(cols, k.col)
+            terms[i] = orderTerm(k.expr, k.dir, k.nulls)
+        }
+        order = " ORDER BY " + strings.Join(terms, ", ")
+    } else {
+        var err error
+        if order, err = svc.orderClause(orderBy); err != nil {
+            return "", nil, nil, nil, err
+        }
+    }
+    query := fmt.Sprintf("SELECT %s FROM %s%s", strings.Join(quoteIdents(cols), ", "), quoteIdent("{{ .Table }}"), b.where()) + order
     if take > 0 {
         query += fmt.Sprintf(" LIMIT %d", take)
     }
     if skip > 0 {
         query += fmt.Sprintf(" OFFSET %d", skip)
     }
+    return query, b.args, cols, keys, nil
+}
+{{- if .Relations }}
+
+// loadRelations loads the included relations of ms, one level deep.
+func (svc *{{ .Name }}Service) loadRelations(ctx context.Context, ms []*{{ .Name }}, include {{ .Name }}Include) error {
+    if len(ms) == 0 {
+        return nil
+    }
+{{- range .Relations }}
+    if include.{{ export .Name }} {
+        if err := svc.load{{ export .Name }}(ctx, ms); err != nil {
+            return err
+        }
+    }
+{{- end }}
+    return nil
+}
+{{- end }}
+{{- $ent := . }}
+{{- range .Relations }}
+{{- $target := entity $.Entities .Type }}
+{{- if .JoinTableName }}
+{{- $pk := primaryKey $ent }}
+{{- $targetPK := primaryKey $target }}
+
+// load{{ export .Name }} loads the {{ .Type }} records linked to each of ms through the
+// {{ .JoinTableName }} join table with a single query.
+func (svc *{{ $ent.Name }}Service) load{{ export .Name }}(ctx context.Context, ms []*{{ $ent.Name }}) error {
+    index := map[[1]interface{}][]*{{ $ent.Name }}{}
+    var keys [][]interface{}
+    for _, m := range ms {
+        m.{{ export .Name }} = nil
+        k := [1]interface{}{m.{{ export $pk.Name }}}
+        if _, ok := index[k]; !ok {
+            keys = append(keys, k[:])
+        }
+        index[k] = append(index[k], m)
+    }
+    owner := "jt." + quoteIdent("{{ .JoinColumn }}")
+    where, args := keysWhere([]string{owner}, keys)
+    query := fmt.Sprintf("SELECT %s, %s FROM %s t JOIN %s jt ON t.%s = jt.%s WHERE %s", owner,
+        strings.Join(qualify("t", {{ unexport .Type }}Columns), ", "), quoteIdent("{{ (entity $.Entities .Type).Table }}"), quoteIdent("{{ .JoinTableName }}"),
+        quoteIdent("{{ $targetPK.Column }}"), quoteIdent("{{ .JoinTarget }}"), where)
     rows, err := svc.db.QueryContext(ctx, query, args...)
     if err != nil {
-        return nil, err
+        return err
     }
     defer rows.Close()
-    var result []*{{ .Name }}
     for rows.Next() {
-        var m {{ .Name }}
-        dest := scanDest(&m)
-        dest = dest[:len(cols)] // Ensure we only scan expected columns
+        var owner {{ $pk.Type }}
+        var related {{ .Type }}
+        dest := append([]interface{}{&owner}, related.scanDest({{ unexport .Type }}Columns)...)
         if err := rows.Scan(dest...); err != nil {
-            return nil, err
+            return err
         }
-        structVal := reflect.ValueOf(&m).Elem()
-        for i := 0; i < len(cols); i++ {
-            switch placeholder := dest[i].(type) {
-            case *sql.NullString:
-                if placeholder.Valid {
-                    structVal.Field(i).SetString(placeholder.String)
-                } else {
-                    structVal.Field(i).SetString("")
-                }
-            case *TimeOrZero:
-                t := time.Time(*placeholder)
-                structVal.Field(i).Set(reflect.ValueOf(t))
-            }
+        for _, m := range index[[1]interface{}{owner}] {
+            m.{{ export .Name }} = append(m.{{ export .Name }}, related)
+        }
+    }
+    return rows.Err()
+}
+{{- else }}
+{{- $join := relationJoin $.Entities $ent . }}
+{{- $n := len $join.Local }}
+
+// load{{ export .Name }} loads the {{ .Type }} {{ if .List }}records{{ else }}record{{ end }} of each of ms with a single query,
+// matching {{ $ent.Name }}.{{ join $join.Local ", " }} to {{ .Type }}.{{ join $join.Remote ", " }}.
+func (svc *{{ $ent.Name }}Service) load{{ export .Name }}(ctx context.Context, ms []*{{ $ent.Name }}) error {
+    index := map[[{{ $n }}]interface{}][]*{{ $ent.Name }}{}
+    var keys [][]interface{}
+    for _, m := range ms {
+        m.{{ export .Name }} = nil
+    {{- range $join.Local }}
+    {{- with nullExpr $.OptionalTypes (fieldOf $ent .) "m" }}
+        if {{ . }} {
+            continue
+        }
+    {{- end }}
+    {{- end }}
+        k := [{{ $n }}]interface{}{ {{- range $i, $f := $join.Local }}{{ if $i }}, {{ end }}{{ valueExpr $.OptionalTypes (fieldOf $ent $f) "m" }}{{ end }} }
+        if _, ok := index[k]; !ok {
+            keys = append(keys, k[:])
         }
-        // Load one‐level relations
-        {{- $ent := . }}
-        {{- range .Relations }}
-            {{- if .JoinTableName }}
-                // load many-to-many {{ .Type }} via join table {{ .JoinTableName }}
-                {
-                colsRel := []string{ {{- range $i,$f := call $.EntitiesMap .Type }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-                rows{{ .Name }}, err := svc.db.QueryContext(ctx,
-                    fmt.Sprintf(
-                        "SELECT %s FROM %s t JOIN %s jt ON t.id = jt.%s_id WHERE jt.%s_id = $1",
-                        strings.Join(colsRel, ", "),
-                        "{{ lower .Type }}",
-                        "{{ lower .JoinTableName }}",
-                        "{{ lower .Type }}",
-                        "{{ lower $ent.Name }}",
-                    ),
-                    m.Id,
-                )
-                if err == nil {
-                    defer rows{{ .Name }}.Close()
-                    for rows{{ .Name }}.Next() {
-                        var related {{ .Type }}
-                        destRel := scanDest(&related)
-                        destRel = destRel[:len(colsRel)]
-                        if err := rows{{ .Name }}.Scan(destRel...); err == nil {
-                            // Copy scanned values into struct fields
-                            structValRel := reflect.ValueOf(&related).Elem()
-                            for i := 0; i < len(colsRel); i++ {
-                                switch placeholder := destRel[i].(type) {
-                                case *sql.NullString:
-                                    if placeholder.Valid {
-                                        structValRel.Field(i).SetString(placeholder.String)
-                                    } else {
-                                        structValRel.Field(i).SetString("")
-                                    }
-                                case *TimeOrZero:
-                                    t := time.Time(*placeholder)
-                                    structValRel.Field(i).Set(reflect.ValueOf(t))
-                                }
-                            }
-                            m.{{ export .Name }} = append(m.{{ export .Name }}, related)
-                        } else {
-                            fmt.Println("Error scanning {{ .Type }}:", err)
-                        }
-                    }
-                }
-                }
-            {{- else }}
-                // one-to-many load of {{ .Type }}
-                {
-                colsRel := []string{ {{- range $i,$f := call $.EntitiesMap .Type }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-                rows{{ .Name }}, err := svc.db.QueryContext(ctx,
-                    fmt.Sprintf("SELECT %s FROM %s WHERE %sid = $1",
-                        strings.Join(colsRel, ", "),
-                        "{{ lower .Type }}",
-                        "{{ lower $ent.Name }}",
-                    ),
-                    m.Id,
-                )
-                if err == nil {
-                    defer rows{{ .Name }}.Close()
-                    for rows{{ .Name }}.Next() {
-                        var related {{ .Type }}
-                        destRel := scanDest(&related)
-                        destRel = destRel[:len(colsRel)]
-                        if err := rows{{ .Name }}.Scan(destRel...); err == nil {
-                            // Copy scanned values into struct fields
-                            structValRel := reflect.ValueOf(&related).Elem()
-                            for i := 0; i < len(colsRel); i++ {
-                                switch placeholder := destRel[i].(type) {
-                                case *sql.NullString:
-                                    if placeholder.Valid {
-                                        structValRel.Field(i).SetString(placeholder.String)
-                                    } else {
-                                        structValRel.Field(i).SetString("")
-                                    }
-                                case *TimeOrZero:
-                                    t := time.Time(*placeholder)
-                                    structValRel.Field(i).Set(reflect.ValueOf(t))
-                                }
-                            }
-                            m.{{ export .Name }} = append(m.{{ export .Name }}, related)
-                        } else {
-                            fmt.Println("Error scanning {{ .Type }}:", err)
-                        }
-                    }
-                }
-                }
-            {{- end }}
+        index[k] = append(index[k], m)
+    }
+    if len(keys) == 0 {
+        return nil
+    }
+    where, args := keysWhere([]string{ {{- range $i, $c := $join.RemoteColumns }}{{ if $i }}, {{ end }}quoteIdent("{{ $c }}"){{ end }} }, keys)
+    cols := {{ unexport .Type }}Columns
+    query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(quoteIdents(cols), ", "), quoteIdent("{{ (entity $.Entities .Type).Table }}"), where)
+    rows, err := svc.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        related := new({{ .Type }})
+        if err := rows.Scan(related.scanDest(cols)...); err != nil {
+            return err
+        }
+    {{- range $join.Remote }}
+    {{- with nullExpr $.OptionalTypes (fieldOf $target .) "related" }}
+        if {{ . }} {
+            continue
+        }
+    {{- end }}
+    {{- end }}
+        k := [{{ $n }}]interface{}{ {{- range $i, $f := $join.Remote }}{{ if $i }}, {{ end }}{{ valueExpr $.OptionalTypes (fieldOf $target $f) "related" }}{{ end }} }
+        for _, m := range index[k] {
+        {{- if .List }}
+            m.{{ export .Name }} = append(m.{{ export .Name }}, *related)
+        {{- else }}
+            m.{{ export .Name }} = related
         {{- end }}
-        result = append(result, &m)
+        }
     }
-    return result, nil
+    return rows.Err()
+}
+{{- end }}
+{{- end }}
+
+// {{ .Name }}CreateInput holds the values of a new {{ .Name }}. Fields with a
+// database default are pointers and are only inserted when set; every other
+// field is inserted as given.{{ if nestedWrites $.Entities . }} Relation fields hold nested writes of related
+// records.{{ end }}
+type {{ .Name }}CreateInput struct {
+{{- range .Fields }}
+{{- if hasDefault . }}
+    {{ export .Name }} *{{ .Type }}
+{{- else }}
+    {{ export .Name }} {{ fieldType $.OptionalTypes . }}
+{{- end }}
+{{- end }}
+{{- range .Relations }}
+{{- if writeKind $.Entities $ent . }}
+    {{ export .Name }} {{ if .List }}ListRelationCreate{{ else }}RelationCreate{{ end }}[{{ .Type }}CreateInput, {{ .Type }}WhereUnique]
+{{- end }}
+{{- end }}
+}
+{{- if nestedWrites $.Entities . }}
+
+// nested reports whether in has nested writes.
+func (in {{ .Name }}CreateInput) nested() bool {
+{{- range .Relations }}
+{{- if writeKind $.Entities $ent . }}
+    if in.{{ export .Name }}.set() {
+        return true
     }
+{{- end }}
+{{- end }}
+    return false
 }
+{{- end }}
 
-// Create inserts a new {{ .Name }} record and updates the passed model with any returned values.
-func (svc *{{ .Name }}Service) Create(ctx context.Context, m *{{ .Name }}) error {
-    // Extract values from the struct into a map
-    data := make(map[string]interface{})
+// data returns the columns to insert for in.{{ if hasUpdatedAt . }} Unset @updatedAt fields are set to
+// now.{{ end }}
+func (in {{ .Name }}CreateInput) data(now time.Time) map[string]interface{} {
+    data := map[string]interface{}{
     {{- range .Fields }}
-    {{- if not .PrimaryKey }}
-    data["{{lower .Name}}"] = m.{{export .Name}}
+    {{- if not (hasDefault .) }}
+        "{{ .Column }}": in.{{ export .Name }},
     {{- end }}
     {{- end }}
+    }
+{{- range .Fields }}
+{{- if hasDefault . }}
+    if in.{{ export .Name }} != nil {
+        data["{{ .Column }}"] = *in.{{ export .Name }}
+    }{{ if .UpdatedAt }} else {
+        data["{{ .Column }}"] = now
+    }{{ end }}
+{{- end }}
+{{- end }}
+    return data
+}
 
-    // Remove empty string entries so they won't be inserted
-    for k, v := range data {
-        if str, ok := v.(string); ok {
-            if str == "" {
-                delete(data, k)
-            }
+// Create inserts a new {{ .Name }} record and returns it with the values set
+// by the database.{{ if nestedWrites $.Entities . }} Nested writes in data run in the same transaction.{{ end }}
+func (svc *{{ .Name }}Service) Create(ctx context.Context, data {{ .Name }}CreateInput) (*{{ .Name }}, error) {
+{{- if nestedWrites $.Entities . }}
+    if data.nested() {
+        var m *{{ .Name }}
+        err := transaction(ctx, svc.db, func(db Executor) error {
+            var err error
+            m, err = (&{{ .Name }}Service{db: db, now: svc.now}).create(ctx, data, nil)
+            return err
+        })
+        return m, err
+    }
+{{- end }}
+    return svc.create(ctx, data, nil)
+}
+
+// create inserts data, overriding its columns with extra.{{ if nestedWrites $.Entities . }} Related records
+// that the new record references are written before it, and records that
+// reference it after it.{{ end }}
+func (svc *{{ .Name }}Service) create(ctx context.Context, data {{ .Name }}CreateInput, extra map[string]interface{}) (*{{ .Name }}, error) {
+    values := data.data(svc.now())
+    for col, v := range extra {
+        values[col] = v
+    }
+{{- range .Relations }}
+{{- if eq (writeKind $.Entities $ent .) "owner" }}
+{{- $join := relationJoin $.Entities $ent . }}
+    if data.{{ export .Name }}.set() {
+        related, err := svc.write{{ export .Name }}(ctx, data.{{ export .Name }}.update())
+        if err != nil {
+            return nil, err
+        }
+    {{- range $i, $c := $join.LocalColumns }}
+        values["{{ $c }}"] = related.{{ export (index $join.Remote $i) }}
+    {{- end }}
+    }
+{{- end }}
+{{- end }}
+    cols, placeholders, args := buildInsert(values)
+    allCols := {{ unexport .Name }}Columns
+    query := fmt.Sprintf("INSERT INTO %s %s RETURNING %s", quoteIdent("{{ .Table }}"), insertValues(cols, placeholders), strings.Join(quoteIdents(allCols), ", "))
+    m := new({{ .Name }})
+    if err := svc.db.QueryRowContext(ctx, query, args...).Scan(m.scanDest(allCols)...); err != nil {
+        return nil, err
+    }
+{{- range .Relations }}
+{{- $kind := writeKind $.Entities $ent . }}
+{{- if or (eq $kind "back") (eq $kind "join") }}
+    if data.{{ export .Name }}.set() {
+        if err := svc.write{{ export .Name }}(ctx, m, data.{{ export .Name }}.update()); err != nil {
+            return nil, err
         }
     }
+{{- end }}
+{{- end }}
+    return m, nil
+}
 
-    cols, placeholders, args := buildInsert(data)
-    colsList := strings.Join(cols, ", ")
-    phList := strings.Join(placeholders, ", ")
-    // Return all columns to repopulate the struct
-    allCols := []string{ {{- range $i,$f := .Fields }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-    query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s", "{{lower .Name}}", colsList, phList, strings.Join(allCols, ", "))
-    row := svc.db.QueryRowContext(ctx, query, args...)
+// Update applies data to the {{ .Name }} matching where and returns the updated
+// record, or sql.ErrNoRows if there is none. Only fields with an operation set
+// in data are written.{{ if nestedWrites $.Entities . }} Nested writes in data run in the same transaction.{{ end }}
+func (svc *{{ .Name }}Service) Update(ctx context.Context, where {{ .Name }}WhereUnique, data {{ .Name }}UpdateInput) (*{{ .Name }}, error) {
+{{- if nestedWrites $.Entities . }}
+    if data.nested() {
+        var m *{{ .Name }}
+        err := transaction(ctx, svc.db, func(db Executor) error {
+            var err error
+            m, err = (&{{ .Name }}Service{db: db, now: svc.now}).update(ctx, where, data)
+            return err
+        })
+        return m, err
+    }
+{{- end }}
+    return svc.update(ctx, where, data)
+}
 
-    dest := scanDest(m)
-    if err := row.Scan(dest...); err != nil {
+// update applies data to the {{ .Name }} matching where.
+func (svc *{{ .Name }}Service) update(ctx context.Context, where {{ .Name }}WhereUnique, data {{ .Name }}UpdateInput) (*{{ .Name }}, error) {
+    whereMap, err := where.toMap()
+    if err != nil {
+        return nil, err
+    }
+    set := &whereBuilder{}
+{{- range .Relations }}
+{{- if eq (writeKind $.Entities $ent .) "owner" }}
+{{- $join := relationJoin $.Entities $ent . }}
+    if data.{{ export .Name }}.set() {
+        related, err := svc.write{{ export .Name }}(ctx, data.{{ export .Name }})
+        if err != nil {
+            return nil, err
+        }
+        if related == nil {
+        {{- if anyRequired $ent $join.Local }}
+            return nil, fmt.Errorf("{{ $ent.Name }}.{{ .Name }} is required and cannot be disconnected")
+        {{- else }}
+        {{- range $join.LocalColumns }}
+            set.add(quoteIdent("{{ . }}") + " = NULL")
+        {{- end }}
+        {{- end }}
+        } else {
+        {{- range $i, $c := $join.LocalColumns }}
+            set.add(quoteIdent("{{ $c }}") + " = " + set.arg(related.{{ export (index $join.Remote $i) }}))
+        {{- end }}
+        }
+    }
+{{- end }}
+{{- end }}
+    if err := data.build(set, svc.now()); err != nil {
+        return nil, err
+    }
+    m := new({{ .Name }})
+    if len(set.conds) == 0 {
+        if m, err = svc.FindUnique(ctx, where); err == nil && m == nil {
+            err = sql.ErrNoRows
+        }
+        if err != nil {
+            return nil, err
+        }
+    } else {
+        whereClause, whereArgs := buildWhereOffset(whereMap, len(set.args)+1)
+        args := append(set.args, whereArgs...)
+        allCols := {{ unexport .Name }}Columns
+        query := fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING %s", quoteIdent("{{ .Table }}"), strings.Join(set.conds, ", "), whereClause, strings.Join(quoteIdents(allCols), ", "))
+        if err := svc.db.QueryRowContext(ctx, query, args...).Scan(m.scanDest(allCols)...); err != nil {
+            return nil, err
+        }
+    }
+{{- range .Relations }}
+{{- $kind := writeKind $.Entities $ent . }}
+{{- if or (eq $kind "back") (eq $kind "join") }}
+    if data.{{ export .Name }}.set() {
+        if err := svc.write{{ export .Name }}(ctx, m, data.{{ export .Name }}); err != nil {
+            return nil, err
+        }
+    }
+{{- end }}
+{{- end }}
+    return m, nil
+}
+
+// updateColumns sets cols on the {{ .Name }} records matching where, or on all
+// records if where is nil, whose columns also equal match. It returns the
+// number of records updated.
+func (svc *{{ .Name }}Service) updateColumns(ctx context.Context, where *{{ .Name }}WhereUnique, match, cols map[string]interface{}) (int64, error) {
+    conds := map[string]interface{}{}
+    if where != nil {
+        whereMap, err := where.toMap()
+        if err != nil {
+            return 0, err
+        }
+        for col, v := range whereMap {
+            conds[col] = v
+        }
+    }
+    for col, v := range match {
+        conds[col] = v
+    }
+    names, _, args := buildInsert(cols)
+    assigns := make([]string, len(names))
+    for i, col := range names {
+        assigns[i] = fmt.Sprintf("%s = $%d", quoteIdent(col), i+1)
+    }
+    whereClause, whereArgs := buildWhereOffset(conds, len(args)+1)
+    query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", quoteIdent("{{ .Table }}"), strings.Join(assigns, ", "), whereClause)
+    res, err := svc.db.ExecContext(ctx, query, append(args, whereArgs...)...)
+    if err != nil {
+        return 0, err
+    }
+    return res.RowsAffected()
+}
+{{- range .Relations }}
+{{- $kind := writeKind $.Entities $ent . }}
+{{- $target := entity $.Entities .Type }}
+{{- $join := relationJoin $.Entities $ent . }}
+{{- if eq $kind "owner" }}
+
+// write{{ export .Name }} applies a nested write of {{ $ent.Name }}.{{ .Name }} and returns the {{ .Type }}
+// record to reference, or nil to disconnect it.
+func (svc *{{ $ent.Name }}Service) write{{ export .Name }}(ctx context.Context, w RelationUpdate[{{ .Type }}CreateInput, {{ .Type }}WhereUnique]) (*{{ .Type }}, error) {
+    if err := w.check(); err != nil {
+        return nil, err
+    }
+    related := &{{ .Type }}Service{db: svc.db, now: svc.now}
+    switch {
+    case w.Create != nil:
+        return related.create(ctx, *w.Create, nil)
+    case w.ConnectOrCreate != nil:
+        m, err := related.FindUnique(ctx, w.ConnectOrCreate.Where)
+        if err != nil || m != nil {
+            return m, err
+        }
+        return related.create(ctx, w.ConnectOrCreate.Create, nil)
+    case w.Connect != nil:
+        return related.FindUniqueOrThrow(ctx, *w.Connect)
+    }
+    return nil, nil
+}
+{{- else if eq $kind "back" }}
+{{- $required := anyRequired $target $join.Remote }}
+
+// write{{ export .Name }} applies a nested write of {{ $ent.Name }}.{{ .Name }} to m by setting the
+// {{ .Type }}.{{ join $join.Remote ", " }} foreign key of the related {{ if .List }}records{{ else }}record{{ end }}.
+func (svc *{{ $ent.Name }}Service) write{{ export .Name }}(ctx context.Context, m *{{ $ent.Name }}, w {{ if .List }}ListRelationUpdate{{ else }}RelationUpdate{{ end }}[{{ .Type }}CreateInput, {{ .Type }}WhereUnique]) error {
+{{- if not .List }}
+    if err := w.check(); err != nil {
+        return err
+    }
+{{- end }}
+    related := &{{ .Type }}Service{db: svc.db, now: svc.now}
+    link := map[string]interface{}{ {{- range $i, $c := $join.RemoteColumns }}{{ if $i }}, {{ end }}"{{ $c }}": m.{{ export (index $join.Local $i) }}{{ end }} }
+{{- if .List }}
+{{- if $required }}
+    if len(w.Disconnect) > 0 || w.Set != nil {
+        return fmt.Errorf("{{ .Type }}.{{ join $join.Remote ", " }} is required, so {{ $ent.Name }}.{{ .Name }} cannot be disconnected")
+    }
+{{- else }}
+    unlink := map[string]interface{}{ {{- range $i, $c := $join.RemoteColumns }}{{ if $i }}, {{ end }}"{{ $c }}": nil{{ end }} }
+    if w.Set != nil {
+        if _, err := related.updateColumns(ctx, nil, link, unlink); err != nil {
+            return err
+        }
+    }
+    for _, where := range w.Disconnect {
+        if _, err := related.updateColumns(ctx, &where, link, unlink); err != nil {
+            return err
+        }
+    }
+{{- end }}
+    for _, data := range w.Create {
+        if _, err := related.create(ctx, data, link); err != nil {
+            return err
+        }
+    }
+    for _, c := range w.ConnectOrCreate {
+        n, err := related.updateColumns(ctx, &c.Where, nil, link)
+        if err == nil && n == 0 {
+            _, err = related.create(ctx, c.Create, link)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    for _, where := range append(append([]{{ .Type }}WhereUnique(nil), w.Set...), w.Connect...) {
+        n, err := related.updateColumns(ctx, &where, nil, link)
+        if err != nil {
+            return err
+        }
+        if n == 0 {
+            return fmt.Errorf("{{ .Type }} not found")
+        }
+    }
+    return nil
+{{- else }}
+    switch {
+    case w.Create != nil:
+        _, err := related.create(ctx, *w.Create, link)
+        return err
+    case w.ConnectOrCreate != nil:
+        n, err := related.updateColumns(ctx, &w.ConnectOrCreate.Where, nil, link)
+        if err == nil && n == 0 {
+            _, err = related.create(ctx, w.ConnectOrCreate.Create, link)
+        }
         return err
+    case w.Connect != nil:
+        n, err := related.updateColumns(ctx, w.Connect, nil, link)
+        if err == nil && n == 0 {
+            err = fmt.Errorf("{{ .Type }} not found")
+        }
+        return err
+    case w.Disconnect:
+{{- if $required }}
+        return fmt.Errorf("{{ .Type }}.{{ join $join.Remote ", " }} is required, so {{ $ent.Name }}.{{ .Name }} cannot be disconnected")
+{{- else }}
+        _, err := related.updateColumns(ctx, nil, link, map[string]interface{}{ {{- range $i, $c := $join.RemoteColumns }}{{ if $i }}, {{ end }}"{{ $c }}": nil{{ end }} })
+        return err
+{{- end }}
+    }
+    return nil
+{{- end }}
+}
+{{- else if eq $kind "join" }}
+{{- $pk := primaryKey $ent }}
+{{- $targetPK := primaryKey $target }}
+
+// write{{ export .Name }} applies a nested write of {{ $ent.Name }}.{{ .Name }} to m through the
+// {{ .JoinTableName }} join table.
+func (svc *{{ $ent.Name }}Service) write{{ export .Name }}(ctx context.Context, m *{{ $ent.Name }}, w ListRelationUpdate[{{ .Type }}CreateInput, {{ .Type }}WhereUnique]) error {
+    related := &{{ .Type }}Service{db: svc.db, now: svc.now}
+    if w.Set != nil {
+        if err := svc.Set{{ export .Name }}(ctx, m.{{ export $pk.Name }}); err != nil {
+            return err
+        }
+    }
+    var gone []{{ $targetPK.Type }}
+    for _, where := range w.Disconnect {
+        r, err := related.FindUnique(ctx, where)
+        if err != nil {
+            return err
+        }
+        if r != nil {
+            gone = append(gone, r.{{ export $targetPK.Name }})
+        }
+    }
+    if err := svc.Disconnect{{ export .Name }}(ctx, m.{{ export $pk.Name }}, gone...); err != nil {
+        return err
+    }
+    var ids []{{ $targetPK.Type }}
+    for _, data := range w.Create {
+        r, err := related.create(ctx, data, nil)
+        if err != nil {
+            return err
+        }
+        ids = append(ids, r.{{ export $targetPK.Name }})
+    }
+    for _, c := range w.ConnectOrCreate {
+        r, err := related.FindUnique(ctx, c.Where)
+        if err == nil && r == nil {
+            r, err = related.create(ctx, c.Create, nil)
+        }
+        if err != nil {
+            return err
+        }
+        ids = append(ids, r.{{ export $targetPK.Name }})
     }
-    structVal := reflect.ValueOf(m).Elem()
-    for i := 0; i < structVal.NumField(); i++ {
-        switch placeholder := dest[i].(type) {
-        case *sql.NullString:
-            if placeholder.Valid {
-                structVal.Field(i).SetString(placeholder.String)
-            } else {
-                structVal.Field(i).SetString("")
-            }
-        case *TimeOrZero:
-            t := time.Time(*placeholder)
-            structVal.Field(i).Set(reflect.ValueOf(t))
+    for _, where := range append(append([]{{ .Type }}WhereUnique(nil), w.Set...), w.Connect...) {
+        r, err := related.FindUniqueOrThrow(ctx, where)
+        if err != nil {
+            return err
         }
+        ids = append(ids, r.{{ export $targetPK.Name }})
     }
-    return nil
+    return svc.Connect{{ export .Name }}(ctx, m.{{ export $pk.Name }}, ids...)
 }
 
-// Update modifies an existing {{ .Name }} record and updates the passed model pointer.
-func (svc *{{ .Name }}Service) Update(ctx context.Context, where map[string]interface{}, m *{{ .Name }}) error {
-    // Extract new values from the struct into a map
-    data := make(map[string]interface{})
-    {{- range .Fields }}
-    {{- if not .PrimaryKey }}
-    data["{{lower .Name}}"] = m.{{export .Name}}
-    {{- end }}
-    {{- end }}
-
-    setClause, setArgs := buildSet(data, 1)
-    whereClause, whereArgs := buildWhereOffset(where, len(setArgs)+1)
-    args := append(setArgs, whereArgs...)
-    allCols := []string{ {{- range $i,$f := .Fields }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-    query := fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING %s", "{{lower .Name}}", setClause, whereClause, strings.Join(allCols, ", "))
-    row := svc.db.QueryRowContext(ctx, query, args...)
+// Connect{{ export .Name }} links the {{ $ent.Name }} with primary key id to the {{ .Type }} records
+// with primary keys ids by inserting rows into the {{ .JoinTableName }} join table.
+// Existing links are left alone, so connecting twice is a no-op.
+func (svc *{{ $ent.Name }}Service) Connect{{ export .Name }}(ctx context.Context, id {{ $pk.Type }}, ids ...{{ $targetPK.Type }}) error {
+    if len(ids) == 0 {
+        return nil
+    }
+    args := []interface{}{id}
+    rows := make([]string, len(ids))
+    for i, related := range ids {
+        args = append(args, related)
+        rows[i] = fmt.Sprintf("($1, $%d)", i+2)
+    }
+    query := fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES %s ON CONFLICT DO NOTHING", quoteIdent("{{ .JoinTableName }}"), quoteIdent("{{ .JoinColumn }}"), quoteIdent("{{ .JoinTarget }}"), strings.Join(rows, ", "))
+    _, err := svc.db.ExecContext(ctx, query, args...)
+    return err
+}
 
-    dest := scanDest(m)
-    if err := row.Scan(dest...); err != nil {
-        return err
+// Disconnect{{ export .Name }} removes the links of the {{ $ent.Name }} with primary key id to
+// the {{ .Type }} records with primary keys ids. Missing links are ignored.
+func (svc *{{ $ent.Name }}Service) Disconnect{{ export .Name }}(ctx context.Context, id {{ $pk.Type }}, ids ...{{ $targetPK.Type }}) error {
+    if len(ids) == 0 {
+        return nil
     }
-    structVal := reflect.ValueOf(m).Elem()
-    for i := 0; i < structVal.NumField(); i++ {
-        switch placeholder := dest[i].(type) {
-        case *sql.NullString:
-            if placeholder.Valid {
-                structVal.Field(i).SetString(placeholder.String)
-            } else {
-                structVal.Field(i).SetString("")
-            }
-        case *TimeOrZero:
-            t := time.Time(*placeholder)
-            structVal.Field(i).Set(reflect.ValueOf(t))
-        }
+    args := []interface{}{id}
+    ph := make([]string, len(ids))
+    for i, related := range ids {
+        args = append(args, related)
+        ph[i] = fmt.Sprintf("$%d", i+2)
     }
-    return nil
+    query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND %s IN (%s)", quoteIdent("{{ .JoinTableName }}"), quoteIdent("{{ .JoinColumn }}"), quoteIdent("{{ .JoinTarget }}"), strings.Join(ph, ", "))
+    _, err := svc.db.ExecContext(ctx, query, args...)
+    return err
+}
+
+// Set{{ export .Name }} replaces the links of the {{ $ent.Name }} with primary key id with links
+// to the {{ .Type }} records with primary keys ids, in one transaction. Without
+// ids it removes all links.
+func (svc *{{ $ent.Name }}Service) Set{{ export .Name }}(ctx context.Context, id {{ $pk.Type }}, ids ...{{ $targetPK.Type }}) error {
+    return transaction(ctx, svc.db, func(db Executor) error {
+        tx := &{{ $ent.Name }}Service{db: db, now: svc.now}
+        query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1", quoteIdent("{{ .JoinTableName }}"), quoteIdent("{{ .JoinColumn }}"))
+        if _, err := tx.db.ExecContext(ctx, query, id); err != nil {
+            return err
+        }
+        return tx.Connect{{ export .Name }}(ctx, id, ids...)
+    })
 }
+{{- end }}
+{{- end }}
 
-// Upsert creates or updates a {{ .Name }} record and updates the passed model pointer.
-func (svc *{{ .Name }}Service) Upsert(ctx context.Context, where map[string]interface{}, m *{{ .Name }}) error {
-    tx, err := svc.db.BeginTx(ctx, nil)
+// Upsert inserts create, or updates the {{ .Name }} matching where with the
+// columns in update if it already exists, in a single INSERT ... ON CONFLICT
+// statement on the unique key selected by where. The values of where are
+// inserted along with create. It returns the created or updated record.
+func (svc *{{ .Name }}Service) Upsert(ctx context.Context, where {{ .Name }}WhereUnique, create {{ .Name }}CreateInput, update {{ .Name }}UpdateInput) (*{{ .Name }}, error) {
+{{- if nestedWrites $.Entities . }}
+    if create.nested() || update.nested() {
+        return nil, errNestedWrite
+    }
+{{- end }}
+    target, err := where.conflictTarget()
     if err != nil {
-        return err
+        return nil, err
     }
-    existing, err := svc.FindUnique(ctx, where)
+    whereMap, err := where.toMap()
     if err != nil {
-        tx.Rollback()
-        return err
+        return nil, err
     }
-    if existing == nil {
-        if err := svc.Create(ctx, m); err != nil {
-            tx.Rollback()
-            return err
-        }
-    } else {
-        if err := svc.Update(ctx, where, m); err != nil {
-            tx.Rollback()
-            return err
-        }
+    data := create.data(svc.now())
+    for col, v := range whereMap {
+        data[col] = v
     }
-    if err := tx.Commit(); err != nil {
-        return err
+    cols, placeholders, args := buildInsert(data)
+    // EXCLUDED is in scope too, so relative updates name the table.
+    set := &whereBuilder{args: args, table: quoteIdent("{{ .Table }}")}
+    if err := update.build(set, svc.now()); err != nil {
+        return nil, err
     }
-    return nil
+    args = set.args
+    setClause := strings.Join(set.conds, ", ")
+    if setClause == "" {
+        // A no-op assignment still makes RETURNING yield the existing row.
+        setClause = quoteIdent(target[0]) + " = EXCLUDED." + quoteIdent(target[0])
+    }
+    allCols := {{ unexport .Name }}Columns
+    query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s RETURNING %s", quoteIdent("{{ .Table }}"),
+        strings.Join(quoteIdents(cols), ", "), strings.Join(placeholders, ", "), strings.Join(quoteIdents(target), ", "), setClause, strings.Join(quoteIdents(allCols), ", "))
+    m := new({{ .Name }})
+    if err := svc.db.QueryRowContext(ctx, query, args...).Scan(m.scanDest(allCols)...); err != nil {
+        return nil, err
+    }
+    return m, nil
 }
 
 // Delete removes a {{ .Name }} record by unique filter.
-func (svc *{{ .Name }}Service) Delete(ctx context.Context, where map[string]interface{}) error {
-    whereClause, args := buildWhere(where)
-    query := fmt.Sprintf("DELETE FROM %s WHERE %s", "{{lower .Name}}", whereClause)
-    _, err := svc.db.ExecContext(ctx, query, args...)
+func (svc *{{ .Name }}Service) Delete(ctx context.Context, where {{ .Name }}WhereUnique) error {
+    whereMap, err := where.toMap()
+    if err != nil {
+        return err
+    }
+    whereClause, args := buildWhere(whereMap)
+    query := fmt.Sprintf("DELETE FROM %s WHERE %s", quoteIdent("{{ .Table }}"), whereClause)
+    _, err = svc.db.ExecContext(ctx, query, args...)
     return err
 }
 
 // Count returns the number of {{ .Name }} records matching 'where'.
-func (svc *{{ .Name }}Service) Count(ctx context.Context, where map[string]interface{}) (int64, error) {
-    whereClause, args := buildWhere(where)
-    query := fmt.Sprintf("SELECT COUNT(*) FROM %s", "{{lower .Name}}")
-    if whereClause != "" {
-        query += " WHERE " + whereClause
-    }
-    row := svc.db.QueryRowContext(ctx, query, args...)
+func (svc *{{ .Name }}Service) Count(ctx context.Context, where {{ .Name }}Where) (int64, error) {
+    b := &whereBuilder{}
+    where.build(b)
+    query := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", quoteIdent("{{ .Table }}"), b.where())
+    row := svc.db.QueryRowContext(ctx, query, b.args...)
     var count int64
     if err := row.Scan(&count); err != nil {
         return 0, err
@@ -757,24 +1600,50 @@ func (svc *{{ .Name }}Service) Count(ctx context.Context, where map[string]inter
 }
 
 // CreateMany inserts multiple {{ .Name }} records in a single statement.
-func (svc *{{ .Name }}Service) CreateMany(ctx context.Context, data []map[string]interface{}) (int64, error) {
+// Columns a row leaves unset take their database default.
+func (svc *{{ .Name }}Service) CreateMany(ctx context.Context, data []{{ .Name }}CreateInput) (int64, error) {
     if len(data) == 0 {
         return 0, nil
     }
-    cols, _, _ := buildInsert(data[0])
+    now := svc.now()
+    rows := make([]map[string]interface{}, len(data))
+    seen := map[string]bool{}
+    var cols []string
+    for i, in := range data {
+{{- if nestedWrites $.Entities . }}
+        if in.nested() {
+            return 0, errNestedWrite
+        }
+{{- end }}
+        rows[i] = in.data(now)
+        for col := range rows[i] {
+            if !seen[col] {
+                seen[col] = true
+                cols = append(cols, col)
+            }
+        }
+    }
+    sort.Strings(cols)
+    if len(cols) == 0 {
+        // DEFAULT VALUES inserts a single row, so name one column instead.
+        cols = {{ unexport .Name }}Columns[:1]
+    }
     var placeholders []string
     var args []interface{}
-    index := 1
-    for _, row := range data {
+    for _, row := range rows {
         var ph []string
         for _, col := range cols {
-            args = append(args, row[col])
-            ph = append(ph, fmt.Sprintf("$%d", index))
-            index++
+            v, ok := row[col]
+            if !ok {
+                ph = append(ph, "DEFAULT")
+                continue
+            }
+            args = append(args, v)
+            ph = append(ph, fmt.Sprintf("$%d", len(args)))
         }
         placeholders = append(placeholders, fmt.Sprintf("(%s)", strings.Join(ph, ", ")))
     }
-    query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", "{{lower .Name}}", strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+    query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quoteIdent("{{ .Table }}"), strings.Join(quoteIdents(cols), ", "), strings.Join(placeholders, ", "))
     res, err := svc.db.ExecContext(ctx, query, args...)
     if err != nil {
         return 0, err
@@ -782,114 +1651,96 @@ func (svc *{{ .Name }}Service) CreateMany(ctx context.Context, data []map[string
     return res.RowsAffected()
 }
 
-// UpdateMany modifies multiple {{ .Name }} records matching 'where' and updates each passed model pointer.
-func (svc *{{ .Name }}Service) UpdateMany(ctx context.Context, where []map[string]interface{}, ms []*{{ .Name }}) (int64, error) {
-    if len(where) != len(ms) {
-        return 0, fmt.Errorf("mismatch between filter list and model list length")
+// UpdateMany applies data to every {{ .Name }} matching where and returns the
+// number of records updated.
+func (svc *{{ .Name }}Service) UpdateMany(ctx context.Context, where {{ .Name }}Where, data {{ .Name }}UpdateInput) (int64, error) {
+{{- if nestedWrites $.Entities . }}
+    if data.nested() {
+        return 0, errNestedWrite
     }
-    var totalAffected int64 = 0
-    for i, m := range ms {
-        filter := where[i]
-        data := make(map[string]interface{})
-        {{- range .Fields }}
-        {{- if not .PrimaryKey }}
-        data["{{lower .Name}}"] = m.{{export .Name}}
-        {{- end }}
-        {{- end }}
-
-        setClause, setArgs := buildSet(data, 1)
-        whereClause, whereArgs := buildWhereOffset(filter, len(setArgs)+1)
-        args := append(setArgs, whereArgs...)
-        allCols := []string{ {{- range $i,$f := .Fields }}{{if $i}}, {{end}}"{{lower $f.Name}}"{{- end }} }
-        query := fmt.Sprintf("UPDATE %s SET %s WHERE %s RETURNING %s", "{{lower .Name}}", setClause, whereClause, strings.Join(allCols, ", "))
-        row := svc.db.QueryRowContext(ctx, query, args...)
-
-        dest := scanDest(m)
-        if err := row.Scan(dest...); err != nil {
-            return totalAffected, err
-        }
-        structVal := reflect.ValueOf(m).Elem()
-        for i := 0; i < structVal.NumField(); i++ {
-            switch placeholder := dest[i].(type) {
-            case *sql.NullString:
-                if placeholder.Valid {
-                    structVal.Field(i).SetString(placeholder.String)
-                } else {
-                    structVal.Field(i).SetString("")
-                }
-            case *TimeOrZero:
-                t := time.Time(*placeholder)
-                structVal.Field(i).Set(reflect.ValueOf(t))
-            }
-        }
-        totalAffected++
+{{- end }}
+    set := &whereBuilder{}
+    if err := data.build(set, svc.now()); err != nil {
+        return 0, err
+    }
+    if len(set.conds) == 0 {
+        return 0, nil
+    }
+    b := &whereBuilder{args: set.args}
+    where.build(b)
+    query := fmt.Sprintf("UPDATE %s SET %s%s", quoteIdent("{{ .Table }}"), strings.Join(set.conds, ", "), b.where())
+    res, err := svc.db.ExecContext(ctx, query, b.args...)
+    if err != nil {
+        return 0, err
     }
-    return totalAffected, nil
+    return res.RowsAffected()
 }
 
-// DeleteMany removes multiple {{ .Name }} records.
-func (svc *{{ .Name }}Service) DeleteMany(ctx context.Context, where map[string]interface{}) (int64, error) {
-    whereClause, args := buildWhere(where)
-    query := fmt.Sprintf("DELETE FROM %s WHERE %s", "{{lower .Name}}", whereClause)
-    res, err := svc.db.ExecContext(ctx, query, args...)
+// DeleteMany removes every {{ .Name }} matching where and returns the number of records deleted.
+func (svc *{{ .Name }}Service) DeleteMany(ctx context.Context, where {{ .Name }}Where) (int64, error) {
+    b := &whereBuilder{}
+    where.build(b)
+    query := fmt.Sprintf("DELETE FROM %s%s", quoteIdent("{{ .Table }}"), b.where())
+    res, err := svc.db.ExecContext(ctx, query, b.args...)
     if err != nil {
         return 0, err
     }
     return res.RowsAffected()
 }
 
-// Aggregate computes SQL aggregates for {{ .Name }}.
-func (svc *{{ .Name }}Service) Aggregate(ctx context.Context, where map[string]interface{}, agg map[string][]string) (map[string]interface{}, error) {
-    selectClauses := []string{}
-    for key, fields := range agg {
-        for _, f := range fields {
-            selectClauses = append(selectClauses, fmt.Sprintf("%s(%s) AS %s_%s", strings.TrimPrefix(key, "_"), f, key, f))
-        }
+// Aggregate computes SQL aggregates for {{ .Name }}. agg maps an aggregate
+// (_count, _sum, _avg, _min or _max) to the fields it is computed over; each
+// result is keyed by the aggregate and field, e.g. "_sum_score".
+func (svc *{{ .Name }}Service) Aggregate(ctx context.Context, where {{ .Name }}Where, agg map[string][]string) (map[string]interface{}, error) {
+    selectClauses, aliases, err := aggregates("{{ .Name }}", {{ unexport .Name }}Columns, agg)
+    if err != nil {
+        return nil, err
     }
-    whereClause, args := buildWhere(where)
-    query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectClauses, ", "), "{{lower .Name}}")
-    if whereClause != "" {
-        query += " WHERE " + whereClause
+    if len(selectClauses) == 0 {
+        return map[string]interface{}{}, nil
     }
-    row := svc.db.QueryRowContext(ctx, query, args...)
-    cols := strings.Split(strings.Join(selectClauses, ", "), ", ")
-    vals := make([]interface{}, len(cols))
-    result := map[string]interface{}{}
-    dest := []interface{}{}
-    for range vals {
-        var v interface{}
-        dest = append(dest, &v)
+    b := &whereBuilder{}
+    where.build(b)
+    query := fmt.Sprintf("SELECT %s FROM %s%s", strings.Join(selectClauses, ", "), quoteIdent("{{ .Table }}"), b.where())
+    row := svc.db.QueryRowContext(ctx, query, b.args...)
+    vals := make([]interface{}, len(aliases))
+    dest := make([]interface{}, len(aliases))
+    for i := range vals {
+        dest[i] = &vals[i]
     }
     if err := row.Scan(dest...); err != nil {
         return nil, err
     }
-    for i, col := range cols {
-        parts := strings.Split(col, " AS ")
-        alias := strings.TrimSpace(parts[1])
-        result[alias] = *(dest[i].(*interface{}))
+    result := map[string]interface{}{}
+    for i, alias := range aliases {
+        result[alias] = vals[i]
     }
     return result, nil
 }
 
 // GroupBy groups {{ .Name }} by specified fields and computes aggregates.
-func (svc *{{ .Name }}Service) GroupBy(ctx context.Context, by []string, where map[string]interface{}, agg map[string][]string) ([]map[string]interface{}, error) {
-    groupClause := strings.Join(by, ", ")
-    selectClauses := []string{}
-    for _, g := range by {
-        selectClauses = append(selectClauses, g)
+func (svc *{{ .Name }}Service) GroupBy(ctx context.Context, by []string, where {{ .Name }}Where, agg map[string][]string) ([]map[string]interface{}, error) {
+    if len(by) == 0 {
+        return nil, fmt.Errorf("{{ .Name }}: GroupBy needs at least one field")
     }
-    for key, fields := range agg {
-        for _, f := range fields {
-            selectClauses = append(selectClauses, fmt.Sprintf("%s(%s) AS %s_%s", strings.TrimPrefix(key, "_"), f, key, f))
+    groupCols := make([]string, len(by))
+    for i, name := range by {
+        col, err := column("{{ .Name }}", {{ unexport .Name }}Columns, name)
+        if err != nil {
+            return nil, err
         }
+        groupCols[i] = col
     }
-    whereClause, args := buildWhere(where)
-    query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectClauses, ", "), "{{lower .Name}}")
-    if whereClause != "" {
-        query += " WHERE " + whereClause
+    aggClauses, _, err := aggregates("{{ .Name }}", {{ unexport .Name }}Columns, agg)
+    if err != nil {
+        return nil, err
     }
-    query += " GROUP BY " + groupClause
-    rows, err := svc.db.QueryContext(ctx, query, args...)
+    selectClauses := append(groupCols[:len(groupCols):len(groupCols)], aggClauses...)
+    b := &whereBuilder{}
+    where.build(b)
+    query := fmt.Sprintf("SELECT %s FROM %s%s", strings.Join(selectClauses, ", "), quoteIdent("{{ .Table }}"), b.where())
+    query += " GROUP BY " + strings.Join(groupCols, ", ")
+    rows, err := svc.db.QueryContext(ctx, query, b.args...)
     if err != nil {
         return nil, err
     }
@@ -911,20 +1762,173 @@ func (svc *{{ .Name }}Service) GroupBy(ctx context.Context, by []string, where m
         }
         results = append(results, rowMap)
     }
-    return results, nil
+    return results, rows.Err()
 }
 
 {{- end }}
 
 // Helper functions used by services
 
+// UnknownColumnError is returned when a column name passed to a query, such as
+// an orderBy entry, a GroupBy field, an aggregate field or a data map key, is
+// not a column of the model.
+type UnknownColumnError struct {
+    Model  string
+    Column string
+}
+
+func (e *UnknownColumnError) Error() string {
+    return fmt.Sprintf("%s has no column %q", e.Model, e.Column)
+}
+
+// UnknownAggregateError is returned when an Aggregate or GroupBy key is not
+// one of _count, _sum, _avg, _min or _max.
+type UnknownAggregateError struct {
+    Model     string
+    Aggregate string
+}
+
+func (e *UnknownAggregateError) Error() string {
+    return fmt.Sprintf("%s: unknown aggregate %q", e.Model, e.Aggregate)
+}
+
+// errNestedWrite is returned when nested writes are passed to a method other
+// than Create and Update.
+var errNestedWrite = errors.New("nested writes are only supported by Create and Update")
+
+// transaction runs fn inside a transaction of db, or a savepoint if db is a
+// session already in one. Other executors run fn directly, leaving the
+// transaction to their owner.
+func transaction(ctx context.Context, db Executor, fn func(db Executor) error) error {
+    s, ok := db.(*runtime.Session)
+    if !ok {
+        return fn(db)
+    }
+    return s.Transaction(ctx, func(tx *runtime.Session) error {
+        return fn(tx)
+    }, nil)
+}
+
+// quoteIdent quotes a SQL identifier.
+func quoteIdent(name string) string {
+    return ` + "`" + `"` + "`" + ` + strings.ReplaceAll(name, ` + "`" + `"` + "`" + `, ` + "`" + `""` + "`" + `) + ` + "`" + `"` + "`" + `
+}
+
+// quoteIdents quotes each identifier of names.
+func quoteIdents(names []string) []string {
+    out := make([]string, len(names))
+    for i, name := range names {
+        out[i] = quoteIdent(name)
+    }
+    return out
+}
+
+// column returns name quoted if it is one of cols, compared case-insensitively
+// like unquoted SQL identifiers, and an *UnknownColumnError otherwise.
+func column(model string, cols []string, name string) (string, error) {
+    col, err := columnName(model, cols, name)
+    if err != nil {
+        return "", err
+    }
+    return quoteIdent(col), nil
+}
+
+// columnName is like column but returns the column as spelled in cols.
+func columnName(model string, cols []string, name string) (string, error) {
+    for _, col := range cols {
+        if strings.EqualFold(col, name) {
+            return col, nil
+        }
+    }
+    return "", &UnknownColumnError{Model: model, Column: name}
+}
+
+var aggregateFuncs = map[string]string{"_count": "COUNT", "_sum": "SUM", "_avg": "AVG", "_min": "MIN", "_max": "MAX"}
+
+// aggregates returns the select expressions for agg and their result aliases,
+// in a stable order.
+func aggregates(model string, cols []string, agg map[string][]string) ([]string, []string, error) {
+    keys := make([]string, 0, len(agg))
+    for key := range agg {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    var exprs, aliases []string
+    for _, key := range keys {
+        fn, ok := aggregateFuncs[key]
+        if !ok {
+            return nil, nil, &UnknownAggregateError{Model: model, Aggregate: key}
+        }
+        for _, f := range agg[key] {
+            col, err := column(model, cols, f)
+            if err != nil {
+                return nil, nil, err
+            }
+            alias := key + "_" + f
+            exprs = append(exprs, fmt.Sprintf("%s(%s) AS %s", fn, col, quoteIdent(alias)))
+            aliases = append(aliases, alias)
+        }
+    }
+    return exprs, aliases, nil
+}
+
+// keysWhere builds a condition matching rows whose cols equal any of keys.
+// A single column is matched with = ANY($1) when the database supports array
+// parameters; otherwise, and for compound keys, an IN list is used.
+func keysWhere(cols []string, keys [][]interface{}) (string, []interface{}) {
+    if len(cols) == 1 && arrayParams {
+        vals := make([]interface{}, len(keys))
+        for i, k := range keys {
+            vals[i] = k[0]
+        }
+        return fmt.Sprintf("%s = ANY($1)", cols[0]), []interface{}{pq.Array(vals)}
+    }
+    var tuples []string
+    var args []interface{}
+    for _, k := range keys {
+        ph := make([]string, len(k))
+        for i, v := range k {
+            args = append(args, v)
+            ph[i] = fmt.Sprintf("$%d", len(args))
+        }
+        tuples = append(tuples, "("+strings.Join(ph, ", ")+")")
+    }
+    return fmt.Sprintf("(%s) IN (%s)", strings.Join(cols, ", "), strings.Join(tuples, ", ")), args
+}
+
+// withColumns returns cols with any missing extra columns appended.
+func withColumns(cols []string, extra ...string) []string {
+    for _, col := range extra {
+        found := false
+        for _, c := range cols {
+            if c == col {
+                found = true
+                break
+            }
+        }
+        if !found {
+            cols = append(cols[:len(cols):len(cols)], col)
+        }
+    }
+    return cols
+}
+
+// qualify quotes each column and prefixes it with a table alias.
+func qualify(alias string, cols []string) []string {
+    out := make([]string, len(cols))
+    for i, col := range cols {
+        out[i] = alias + "." + quoteIdent(col)
+    }
+    return out
+}
+
 // buildWhere assembles SQL WHERE clause and args
 func buildWhere(where map[string]interface{}) (string, []interface{}) {
     var clauses []string
     var args []interface{}
     i := 1
     for k, v := range where {
-        clauses = append(clauses, fmt.Sprintf("%s = $%d", k, i))
+        clauses = append(clauses, fmt.Sprintf("%s = $%d", quoteIdent(k), i))
         args = append(args, v)
         i++
     }
@@ -937,53 +1941,54 @@ func buildWhereOffset(where map[string]interface{}, start int) (string, []interf
     var args []interface{}
     i := start
     for k, v := range where {
-        clauses = append(clauses, fmt.Sprintf("%s = $%d", k, i))
+        clauses = append(clauses, fmt.Sprintf("%s = $%d", quoteIdent(k), i))
         args = append(args, v)
         i++
     }
     return strings.Join(clauses, " AND "), args
 }
 
-// buildInsert assembles INSERT columns, placeholders, and args
+// buildInsert assembles INSERT columns, placeholders, and args, ordered by column
 func buildInsert(data map[string]interface{}) ([]string, []string, []interface{}) {
     var cols []string
+    for k := range data {
+        cols = append(cols, k)
+    }
+    sort.Strings(cols)
     var placeholders []string
     var args []interface{}
-    i := 1
-    for k, v := range data {
-        cols = append(cols, k)
-        placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-        args = append(args, v)
-        i++
+    for i, col := range cols {
+        placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+        args = append(args, data[col])
     }
     return cols, placeholders, args
 }
 
-// buildSet assembles SET clause and args
-func buildSet(data map[string]interface{}, start int) (string, []interface{}) {
-    var clauses []string
-    var args []interface{}
-    i := start
-    for k, v := range data {
-        clauses = append(clauses, fmt.Sprintf("%s = $%d", k, i))
-        args = append(args, v)
-        i++
+// insertValues returns the column list and VALUES clause of an INSERT, or
+// DEFAULT VALUES if there are no columns.
+func insertValues(cols, placeholders []string) string {
+    if len(cols) == 0 {
+        return "DEFAULT VALUES"
     }
-    return strings.Join(clauses, ", "), args
+    return fmt.Sprintf("(%s) VALUES (%s)", strings.Join(quoteIdents(cols), ", "), strings.Join(placeholders, ", "))
 }
 `))
 
 // Generate reads a Prisma schema and outputs Go client code.
 func Generate(schemaPath, outDir string) error {
-	data, err := os.ReadFile(schemaPath)
-	if err != nil {
-		return err
-	}
-	ast, err := ParseSchema(data)
+	ast, err := ParseSchemaFile(schemaPath)
 	if err != nil {
 		return err
 	}
 	fmt.Printf("Parsed %d entities\n", len(ast.Entities))
+	optionalTypes := generatorOptions(ast)["optionalTypes"]
+	switch optionalTypes {
+	case "":
+		optionalTypes = optionalPointer
+	case optionalPointer, optionalSQLNull:
+	default:
+		return fmt.Errorf("generator option optionalTypes must be %q or %q, got %q", optionalPointer, optionalSQLNull, optionalTypes)
+	}
 	if err := os.MkdirAll(outDir, 0o755); err != nil {
 		return err
 	}
@@ -1027,7 +2032,7 @@ func Generate(schemaPath, outDir string) error {
 		}
 		defer f.Close()
 
-		if err := modelTemplate.Execute(f, ent); err != nil {
+		if err := modelTemplate.Execute(f, modelData{Entity: ent, OptionalTypes: optionalTypes}); err != nil {
 			return err
 		}
 		if err := f.Close(); err != nil {
@@ -1042,6 +2047,24 @@ func Generate(schemaPath, outDir string) error {
 		fmt.Printf("Generated model %s\n", filePath)
 	}
 
+	// Generate filters.go with the filter types used by the Where inputs
+	filtersPath := filepath.Join(outDir, "filters.go")
+	ff, err := os.Create(filtersPath)
+	if err != nil {
+		return err
+	}
+	defer ff.Close()
+	if err := filterTemplate.Execute(ff, map[string]interface{}{"Filters": usedFilters(ast), "Relations": hasRelations(ast)}); err != nil {
+		return err
+	}
+	if err := ff.Close(); err != nil {
+		return err
+	}
+	if err := formatFile(filtersPath); err != nil {
+		return err
+	}
+	fmt.Printf("Generated filters %s\n", filtersPath)
+
 	// Generate client.go with service methods for all models
 	clientPath := filepath.Join(outDir, "client.go")
 	cf, err := os.Create(clientPath)
@@ -1050,17 +2073,12 @@ func Generate(schemaPath, outDir string) error {
 	}
 	defer cf.Close()
 
-	// Build EntitiesMap: function for lookup of entity fields by name
 	dataMap := map[string]interface{}{
-		"Entities": ast.Entities,
-		"EntitiesMap": func(name string) []Field {
-			for _, e := range ast.Entities {
-				if e.Name == name {
-					return e.Fields
-				}
-			}
-			return nil
-		},
+		"Entities":      ast.Entities,
+		"HasUUID":       clientUsesType(ast, "uuid.UUID"),
+		"HasJSON":       clientUsesType(ast, "json.RawMessage"),
+		"OptionalTypes": optionalTypes,
+		"ArrayParams":   supportsArrayParams(ast),
 	}
 	if err := clientTemplate.Execute(cf, dataMap); err != nil {
 		return err
@@ -1080,3 +2098,279 @@ func Generate(schemaPath, outDir string) error {
 
 	return nil
 }
+
+// uniqueFields returns the fields that identify a single record on their own:
+// the primary key and every @unique field.
+func uniqueFields(ent Entity) []Field {
+	var out []Field
+	for _, f := range ent.Fields {
+		if f.PrimaryKey || f.Unique {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// compoundKeys returns the multi-field keys that identify a single record:
+// the @@id primary key, if any, followed by every @@unique constraint.
+func compoundKeys(ent Entity) []Index {
+	var out []Index
+	if len(ent.PrimaryKey) > 0 {
+		out = append(out, Index{Fields: ent.PrimaryKey})
+	}
+	return append(out, ent.UniqueConstraints...)
+}
+
+// compoundName builds the Go name of a compound key, e.g. [firstName lastName] -> FirstNameLastName.
+func compoundName(fields []string) string {
+	var sb strings.Builder
+	for _, f := range fields {
+		sb.WriteString(strings.ToUpper(f[:1]) + f[1:])
+	}
+	return sb.String()
+}
+
+// clientUsesType reports whether client.go references the given Go type.
+// Every field appears in the update input or, for primary keys, in the unique
+// selector, so this holds if any field has the type.
+func clientUsesType(ast AST, goType string) bool {
+	for _, ent := range ast.Entities {
+		for _, f := range ent.Fields {
+			if strings.TrimPrefix(f.Type, "[]") == goType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Optional field representations, selected with the optionalTypes option of
+// the TORM generator block.
+const (
+	optionalPointer = "pointer"
+	optionalSQLNull = "sqlnull"
+)
+
+// modelData is the input of modelTemplate.
+type modelData struct {
+	Entity
+	OptionalTypes string
+}
+
+// goFieldType returns the Go type of a model field. Optional fields become
+// pointers, or sql.Null[T] with optionalTypes = "sqlnull"; slices and maps
+// already represent NULL as nil.
+func goFieldType(optionalTypes string, f Field) string {
+	if f.NotNull || f.PrimaryKey || strings.HasPrefix(f.Type, "[]") {
+		return f.Type
+	}
+	if optionalTypes == optionalSQLNull {
+		return "sql.Null[" + f.Type + "]"
+	}
+	return "*" + f.Type
+}
+
+// generatorOptions returns the options of the schema's TORM generator block.
+func generatorOptions(ast AST) map[string]string {
+	for _, g := range ast.Generators {
+		if strings.Contains(strings.ToLower(g.Provider), "torm") {
+			return g.Options
+		}
+	}
+	return map[string]string{}
+}
+
+// relationJoin describes how the rows of a relation are matched: the target
+// columns Remote equal this model's fields Local, pairwise. LocalColumns and
+// RemoteColumns hold the database columns of those fields.
+type relationJoin struct {
+	Local         []string
+	Remote        []string
+	LocalColumns  []string
+	RemoteColumns []string
+}
+
+// joinFor returns the join of rel, declared on ent. The owning side declares
+// the foreign key itself; the back side uses the opposite relation's.
+func joinFor(ents []Entity, ent Entity, rel Relation) relationJoin {
+	var join relationJoin
+	switch {
+	case len(rel.Fields) > 0:
+		join = relationJoin{Local: rel.Fields, Remote: rel.References}
+	case rel.JoinTableName != "":
+		// Many-to-many relations are joined on the primary key.
+		join = relationJoin{Local: []string{primaryKey(ent).Name}}
+	default:
+		if opp := oppositeRelation(ents, ent.Name, rel); opp != nil {
+			join = relationJoin{Local: opp.References, Remote: opp.Fields}
+		}
+	}
+	target := entityByName(ents, rel.Type)
+	for _, name := range join.Local {
+		join.LocalColumns = append(join.LocalColumns, fieldOf(ent, name).Column)
+	}
+	for _, name := range join.Remote {
+		join.RemoteColumns = append(join.RemoteColumns, fieldOf(target, name).Column)
+	}
+	return join
+}
+
+// writeKind returns how a nested write of rel, declared on ent, is applied:
+// "join" through a join table, "owner" by setting ent's foreign key, "back"
+// by setting the foreign key of the related records, or "" if rel cannot be
+// written.
+func writeKind(ents []Entity, ent Entity, rel Relation) string {
+	switch join := joinFor(ents, ent, rel); {
+	case rel.JoinTableName != "":
+		return "join"
+	case len(rel.Fields) > 0:
+		return "owner"
+	case len(join.Remote) > 0:
+		return "back"
+	}
+	return ""
+}
+
+// nestedWrites reports whether any relation of ent can be written by a nested write.
+func nestedWrites(ents []Entity, ent Entity) bool {
+	for _, rel := range ent.Relations {
+		if writeKind(ents, ent, rel) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// anyRequired reports whether any of the named fields of ent is required.
+func anyRequired(ent Entity, names []string) bool {
+	for _, f := range ent.Fields {
+		for _, name := range names {
+			if f.Name == name && f.NotNull {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Where renders the join as a SQL condition on the target columns with
+// placeholders $1..$n for the local values.
+func (j relationJoin) Where() string {
+	conds := make([]string, len(j.RemoteColumns))
+	for i, col := range j.RemoteColumns {
+		conds[i] = fmt.Sprintf("%s = $%d", col, i+1)
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// primaryKeys returns the primary key fields of ent: its @id field, or the
+// fields of its @@id.
+func primaryKeys(ent Entity) []Field {
+	var pks []Field
+	for _, f := range ent.Fields {
+		if f.PrimaryKey {
+			pks = append(pks, f)
+		}
+	}
+	for _, name := range ent.PrimaryKey {
+		pks = append(pks, fieldOf(ent, name))
+	}
+	return pks
+}
+
+// primaryKey returns the @id field of ent.
+func primaryKey(ent Entity) Field {
+	for _, f := range ent.Fields {
+		if f.PrimaryKey {
+			return f
+		}
+	}
+	return Field{}
+}
+
+// isPrimaryKey reports whether f is one of the primary key fields of ent.
+func isPrimaryKey(ent Entity, f Field) bool {
+	if f.PrimaryKey {
+		return true
+	}
+	for _, name := range ent.PrimaryKey {
+		if name == f.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldOf returns the scalar field of ent with the given name.
+func fieldOf(ent Entity, name string) Field {
+	for _, f := range ent.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return Field{}
+}
+
+// entityByName returns the model with the given name.
+func entityByName(ents []Entity, name string) Entity {
+	for _, e := range ents {
+		if e.Name == name {
+			return e
+		}
+	}
+	return Entity{}
+}
+
+// supportsArrayParams reports whether the schema's datasource accepts array
+// parameters. Schemas without a datasource are assumed to target Postgres.
+func supportsArrayParams(ast AST) bool {
+	for _, ds := range ast.Datasources {
+		switch ds.Provider {
+		case "postgres", "postgresql", "cockroachdb":
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// exportName upper-cases the first letter of a schema name.
+func exportName(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// hasDefault reports whether the database fills in f when an insert omits it;
+// migrations give exactly these columns a default.
+func hasDefault(f Field) bool {
+	return f.Default != nil || f.AutoIncrement
+}
+
+// valueExpr returns a Go expression for the value of field f on variable v,
+// dereferencing optional fields. It is only valid where nullExpr is false.
+func valueExpr(optionalTypes string, f Field, v string) string {
+	expr := v + "." + exportName(f.Name)
+	switch t := goFieldType(optionalTypes, f); {
+	case strings.HasPrefix(t, "*"):
+		return "*" + expr
+	case strings.HasPrefix(t, "sql.Null["):
+		return expr + ".V"
+	}
+	return expr
+}
+
+// nullExpr returns a Go condition that holds when field f on variable v is
+// NULL, or "" if the field cannot be NULL.
+func nullExpr(optionalTypes string, f Field, v string) string {
+	expr := v + "." + exportName(f.Name)
+	switch t := goFieldType(optionalTypes, f); {
+	case strings.HasPrefix(t, "*"):
+		return expr + " == nil"
+	case strings.HasPrefix(t, "sql.Null["):
+		return "!" + expr + ".Valid"
+	}
+	return ""
+}
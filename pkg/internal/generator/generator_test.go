@@ -3,7 +3,9 @@ package generator
 import (
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -17,12 +19,80 @@ datasource db {
 
 model Book {
   id     String   @id @default(uuid()) @db.Uuid
+  isbn   String   @unique
   title  String
   pages  Int
   read   Boolean @default(false)
+  updatedAt DateTime @updatedAt
+  blurb  String?
+  authorId String? @db.Uuid
+  author   Author? @relation(fields: [authorId], references: [id])
+
+  @@unique([title, pages])
+}
+
+model Author {
+  id    String @id @default(uuid()) @db.Uuid
+  books Book[]
+  tags  Tag[]
+}
+
+model Tag {
+  id      Int      @id @default(autoincrement())
+  authors Author[]
+}
+
+model Review {
+  bookId String @db.Uuid
+  reader String
+  stars  Int    @map("star_count")
+
+  @@id([bookId, reader])
+  @@map("book_reviews")
 }
 `
 
+// writeGoMod writes a go.mod to dir that resolves the TORM runtime package,
+// which the generated client imports, to this repository.
+func writeGoMod(t *testing.T, dir string) {
+	t.Helper()
+	root, err := filepath.Abs(filepath.Join("..", "..", ".."))
+	if err != nil {
+		t.Fatalf("failed to resolve module root: %v", err)
+	}
+	goModContent := []byte("module example.com/tormtest\n\ngo 1.23\n\nrequire github.com/TechXTT/TORM v0.0.0\n\nreplace github.com/TechXTT/TORM => " + root + "\n")
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), goModContent, 0644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+}
+
+// generateWithTest generates schema into dir along with a test file named name,
+// written first so that go mod tidy picks up its imports.
+func generateWithTest(t *testing.T, dir, schema, name, test string) {
+	t.Helper()
+	schemaPath := filepath.Join(dir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	writeGoMod(t, dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(test), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	if err := Generate(schemaPath, dir); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+}
+
+// goTest runs the tests of the generated package in dir.
+func goTest(t *testing.T, dir string) {
+	t.Helper()
+	cmd := exec.Command("go", "test", "./...")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("generated client tests failed: %v\n%s", err, out)
+	}
+}
+
 func TestGenerate_WritesModelAndClient(t *testing.T) {
 	// Create a temporary directory to act as output
 	tmpDir, err := ioutil.TempDir("", "torm-gen-test")
@@ -38,10 +108,7 @@ func TestGenerate_WritesModelAndClient(t *testing.T) {
 	}
 
 	// Create a go.mod in the outDir so that go mod tidy can run without error
-	goModContent := []byte("module example.com/tormtest\n\ngo 1.21")
-	if err := ioutil.WriteFile(filepath.Join(tmpDir, "go.mod"), goModContent, 0644); err != nil {
-		t.Fatalf("failed to write go.mod: %v", err)
-	}
+	writeGoMod(t, tmpDir)
 
 	// Call Generate
 	if err := Generate(schemaPath, tmpDir); err != nil {
@@ -74,6 +141,13 @@ func TestGenerate_WritesModelAndClient(t *testing.T) {
 		t.Errorf("book.go missing uuid import; got:\n%s", string(bookContents))
 	}
 
+	// Optional fields are generated as pointers by default, as are singular relations
+	for _, want := range []string{`Blurb\s+\*string`, `Author\s+\*Author`} {
+		if !regexp.MustCompile(want).Match(bookContents) {
+			t.Errorf("book.go does not match %q; got:\n%s", want, string(bookContents))
+		}
+	}
+
 	// Verify that required imports and service definitions appear in client.go
 	clientContents, err := ioutil.ReadFile(clientPath)
 	if err != nil {
@@ -85,4 +159,978 @@ func TestGenerate_WritesModelAndClient(t *testing.T) {
 	if !strings.Contains(string(clientContents), "type BookService") {
 		t.Errorf("client.go missing BookService definition; got:\n%s", string(clientContents))
 	}
+
+	// FindUnique only accepts primary key and unique fields
+	for _, want := range []string{
+		`func \(svc \*BookService\) FindUnique\(ctx context.Context, where BookWhereUnique, opts \.\.\.BookQueryOption\)`,
+		`Isbn\s+\*string`,
+		`TitlePages\s+\*BookTitlePagesKey`,
+		`type BookTitlePagesKey struct`,
+		// a composite primary key is selected with a compound key
+		`type ReviewWhereUnique struct {\s+BookIdReader \*ReviewBookIdReaderKey\s+}`,
+		`type ReviewBookIdReaderKey struct {\s+BookId\s+uuid.UUID\s+Reader string\s+}`,
+		`cols := \[\]string\{"bookid", "reader"\}`,
+		// @map and @@map names are used in queries, Go names stay unchanged
+		`var reviewColumns = \[\]string\{"bookid", "reader", "star_count"\}`,
+		`ReviewFieldStars\s+ReviewField = "star_count"`,
+		`"SELECT %s FROM %s%s LIMIT 1", strings.Join\(quoteIdents\(cols\), ", "\), quoteIdent\("book_reviews"\)`,
+		`func \(svc \*BookService\) loadAuthor\(ctx context.Context, ms \[\]\*Book\) error`,
+		// relations are only loaded when included
+		`type BookInclude struct {\s+Author bool\s+}`,
+		`func \(svc \*BookService\) FindMany\(ctx context.Context, where BookWhere, orderBy \[\]BookOrderBy, skip, take int, opts \.\.\.BookQueryOption\)`,
+		`if include.Author {\s+if err := svc.loadAuthor\(ctx, ms\); err != nil`,
+		`func \(s BookSelect\) applyBookQuery\(q \*bookQuery\)`,
+		// relation loading follows the declared foreign key
+		`where, args := keysWhere\(\[\]string\{quoteIdent\("authorid"\)\}, keys\)`,
+		// relations of a whole page are loaded with one query per relation
+		`func \(svc \*AuthorService\) loadBooks\(ctx context.Context, ms \[\]\*Author\) error`,
+		`const arrayParams = true`,
+		// filters are typed per field
+		`type BookWhere struct {\s+Id\s+UUIDFilter\s+Isbn\s+StringFilter\s+Title\s+StringFilter\s+Pages\s+IntFilter`,
+		`w.Blurb.build\(b, t\+"."\+quoteIdent\("blurb"\)\)`,
+		// relation filters correlate an EXISTS subquery through the foreign key
+		`Author\s+RelationFilter\[AuthorWhere\]`,
+		`Books\s+ListRelationFilter\[BookWhere\]`,
+		`w.Author.build\(b, t, link\{table: "author", local: \[\]string\{"authorid"\}, remote: \[\]string\{"id"\}\}\)`,
+		`w.Books.build\(b, t, link\{table: "book", local: \[\]string\{"id"\}, remote: \[\]string\{"authorid"\}\}\)`,
+		// caller-supplied identifiers are checked against the columns and quoted
+		`type UnknownColumnError struct`,
+		`col, err := column\("Book", bookColumns, name\)`,
+		`selectClauses, aliases, err := aggregates\("Book", bookColumns, agg\)`,
+		// typed ordering, including by relation field and relation count
+		`BookFieldTitle\s+BookField = "title"`,
+		`type BookOrderBy struct {\s+Field\s+BookField\s+Author\s+\*AuthorOrderBy`,
+		`AuthorRelationBooks\s+AuthorRelation = "books"`,
+		`return orderByRelation\(t, depth, link\{table: "author", local: \[\]string\{"authorid"\}, remote: \[\]string\{"id"\}\}, o.Author.expr\)`,
+		`case AuthorRelationBooks:\s+return orderByCount\(t, depth, link\{table: "book", local: \[\]string\{"id"\}, remote: \[\]string\{"authorid"\}\}\), o.Dir, o.Nulls, nil`,
+		// keyset pagination with opaque cursors
+		`type BookCursor string`,
+		// services run on a database or inside a transaction
+		`type BookService struct {\s+db\s+Executor\s+now func\(\) time.Time\s+}`,
+		`func \(t \*TxClient\) BookService\(\) \*BookService`,
+		`func \(c \*Client\) Transaction\(ctx context.Context, fn func\(tx \*TxClient\) error, opts \.\.\.TxOption\) error`,
+		// upserts are a single INSERT ... ON CONFLICT statement
+		`func \(svc \*BookService\) Upsert\(ctx context.Context, where BookWhereUnique, create BookCreateInput, update BookUpdateInput\) \(\*Book, error\)`,
+		// updates only write the fields that are set
+		`func \(svc \*BookService\) Update\(ctx context.Context, where BookWhereUnique, data BookUpdateInput\) \(\*Book, error\)`,
+		`type BookUpdateInput struct {\s+Isbn\s+FieldUpdate\[string\]\s+Title\s+FieldUpdate\[string\]\s+Pages\s+NumberUpdate\[int\]`,
+		`if err := in.Blurb.build\(b, "blurb", true\); err != nil`,
+		`ON CONFLICT \(%s\) DO UPDATE SET %s RETURNING %s`,
+		`if w.TitlePages != nil \{\s+target = \[\]string\{"title", "pages"\}`,
+		`func NewClientFromSession\(s \*runtime.Session\) \*Client`,
+		`func \(t \*TxClient\) Transaction\(ctx context.Context, fn func\(tx \*TxClient\) error\) error`,
+		// lazily scanned results
+		`func \(svc \*BookService\) FindManyIter\(ctx context.Context, where BookWhere, orderBy \[\]BookOrderBy, skip, take int, opts \.\.\.BookQueryOption\) iter.Seq2\[\*Book, error\]`,
+		`if !yield\(m, nil\) \{\s+return\s+\}`,
+		`func \(svc \*BookService\) FindPage\(ctx context.Context, where BookWhere, orderBy \[\]BookOrderBy, take int, after BookCursor, opts \.\.\.BookQueryOption\) \(\[\]\*Book, BookCursor, error\)`,
+		`var bookNullable = map\[string\]bool\{"blurb": true, "authorid": true`,
+		`if !seen\["id"\] \{\s+keys = append\(keys, orderKey\{col: "id", expr: quoteIdent\("book"\) \+ "." \+ quoteIdent\("id"\)\}\)`,
+		// @updatedAt fields are set on every write from the client's clock
+		`func \(c \*Client\) WithClock\(now func\(\) time.Time\) \*Client`,
+		`if in.UpdatedAt != nil \{\s+data\["updatedat"\] = \*in.UpdatedAt\s+\} else \{\s+data\["updatedat"\] = now`,
+		`if in.UpdatedAt.Set == nil && !in.UpdatedAt.SetNull \{\s+b.add\(quoteIdent\("updatedat"\) \+ " = " \+ b.arg\(now\)\)`,
+		// fields with a default are only inserted when set; the rest as given
+		`func \(svc \*BookService\) Create\(ctx context.Context, data BookCreateInput\) \(\*Book, error\)`,
+		`type BookCreateInput struct {\s+Id\s+\*uuid.UUID\s+Isbn\s+string\s+Title\s+string\s+Pages\s+int\s+Read\s+\*bool`,
+		`data := map\[string\]interface\{\}\{\s+"isbn":\s+in.Isbn,`,
+		`return "DEFAULT VALUES"`,
+		// nested writes run in one transaction, the referenced record first
+		`Author\s+RelationCreate\[AuthorCreateInput, AuthorWhereUnique\]`,
+		`Books\s+ListRelationUpdate\[BookCreateInput, BookWhereUnique\]`,
+		`m, err = \(&BookService\{db: db, now: svc.now\}\).create\(ctx, data, nil\)`,
+		`related, err := svc.writeAuthor\(ctx, data.Author.update\(\)\)\s+if err != nil \{\s+return nil, err\s+\}\s+values\["authorid"\] = related.Id`,
+		`func \(svc \*AuthorService\) writeBooks\(ctx context.Context, m \*Author, w ListRelationUpdate\[BookCreateInput, BookWhereUnique\]\) error`,
+		`link := map\[string\]interface\{\}\{"authorid": m.Id\}`,
+		`for _, where := range append\(append\(\[\]BookWhereUnique\(nil\), w.Set\.\.\.\), w.Connect\.\.\.\)`,
+		`for _, where := range append\(append\(\[\]TagWhereUnique\(nil\), w.Set\.\.\.\), w.Connect\.\.\.\)`,
+		`if data.nested\(\) \{\s+return 0, errNestedWrite`,
+		// many-to-many links are written to the join table
+		`func \(svc \*AuthorService\) ConnectTags\(ctx context.Context, id uuid.UUID, ids \.\.\.int\) error`,
+		`INSERT INTO %s \(%s, %s\) VALUES %s ON CONFLICT DO NOTHING", quoteIdent\("author_tag"\), quoteIdent\("author_id"\), quoteIdent\("tag_id"\)`,
+		`func \(svc \*TagService\) DisconnectAuthors\(ctx context.Context, id int, ids \.\.\.uuid.UUID\) error`,
+		`func \(svc \*AuthorService\) SetTags\(ctx context.Context, id uuid.UUID, ids \.\.\.int\) error`,
+		`OR\s+\[\]BookWhere`,
+		`anyOf\(b, w.OR\)\s+noneOf\(b, w.NOT\)`,
+	} {
+		if !regexp.MustCompile(want).Match(clientContents) {
+			t.Errorf("client.go does not match %q; got:\n%s", want, string(clientContents))
+		}
+	}
+
+	// filter types are generated once for the whole package
+	filtersContents, err := ioutil.ReadFile(filepath.Join(tmpDir, "filters.go"))
+	if err != nil {
+		t.Fatalf("failed to read filters.go: %v", err)
+	}
+	for _, want := range []string{
+		`type StringFilter struct`,
+		`type UUIDFilter struct`,
+		`func Ptr\[T any\]\(v T\) \*T`,
+		`func anyOf\[W condition\]\(b \*whereBuilder, ws \[\]W\)`,
+		`type ListRelationFilter\[W condition\] struct`,
+		`func orderTerm\(expr string, dir SortOrder, nulls NullsOrder\) string`,
+		`func after\(b \*whereBuilder, keys \[\]orderKey, vals \[\]interface\{\}\)`,
+		`type NumberUpdate\[T int \| int64 \| float64\] struct`,
+		`type ListRelationUpdate\[C, U any\] struct`,
+		`%s \(SELECT 1 FROM %s WHERE %s\)`,
+		`lhs, rhs, like = "LOWER\("\+col\+"\)", "LOWER\(%s\)", "ILIKE"`,
+	} {
+		if !regexp.MustCompile(want).Match(filtersContents) {
+			t.Errorf("filters.go does not match %q; got:\n%s", want, string(filtersContents))
+		}
+	}
+	if strings.Contains(string(filtersContents), "type FloatFilter") {
+		t.Errorf("filters.go should only contain filters used by the schema")
+	}
+}
+
+// jsonRoundTripTest is run against the generated client: Json values are bound
+// as arguments and scanned back, NULL included.
+const jsonRoundTripTest = `package models
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewClientFromSession(runtime.NewSession(db)).DocService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(` + "`" + `INSERT INTO "doc"` + "`" + `).
+		WithArgs([]byte(` + "`" + `{"a":1}` + "`" + `), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "meta", "note"}).AddRow(1, []byte(` + "`" + `{"a":1}` + "`" + `), nil))
+	doc, err := svc.Create(context.Background(), DocCreateInput{Meta: json.RawMessage(` + "`" + `{"a":1}` + "`" + `)})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if string(doc.Meta) != ` + "`" + `{"a":1}` + "`" + ` || doc.Note != nil {
+		t.Errorf("scanned Meta=%s Note=%v", doc.Meta, doc.Note)
+	}
+
+	note := json.RawMessage("[1,2]")
+	mock.ExpectQuery(` + "`" + `SELECT "id", "meta", "note" FROM "doc"` + "`" + `).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "meta", "note"}).AddRow(1, []byte("{}"), []byte(note)))
+	doc, err = svc.FindUnique(context.Background(), DocWhereUnique{Id: Ptr(1)})
+	if err != nil {
+		t.Fatalf("FindUnique: %v", err)
+	}
+	if doc.Note == nil || string(*doc.Note) != "[1,2]" {
+		t.Errorf("scanned Note=%v", doc.Note)
+	}
+}
+`
+
+func TestGenerate_JSONRoundTrip(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-json")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+model Doc {
+  id   Int   @id @default(autoincrement())
+  meta Json
+  note Json?
+}
+`
+	generateWithTest(t, tmpDir, schema, "json_test.go", jsonRoundTripTest)
+	docContents, err := ioutil.ReadFile(filepath.Join(tmpDir, "doc.go"))
+	if err != nil {
+		t.Fatalf("failed to read doc.go: %v", err)
+	}
+	for _, want := range []string{
+		`"encoding/json"`,
+		`Meta\s+json\.RawMessage`,
+		`Note\s+\*json\.RawMessage`,
+	} {
+		if !regexp.MustCompile(want).Match(docContents) {
+			t.Errorf("doc.go does not match %q; got:\n%s", want, string(docContents))
+		}
+	}
+
+	goTest(t, tmpDir)
+}
+
+// upsertIncrementTest is run against the generated client: relative updates in
+// Upsert read the existing row, which must be named next to EXCLUDED.
+const upsertIncrementTest = `package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestUpsertIncrement(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewClientFromSession(runtime.NewSession(db)).CounterService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(` + "`" + `INSERT INTO "counter" ("hits", "name") VALUES ($1, $2) ON CONFLICT ("name") DO UPDATE SET "hits" = "counter"."hits" + $3 RETURNING "id", "name", "hits"` + "`" + `).
+		WithArgs(1, "a", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "hits"}).AddRow(1, "a", 6))
+	_, err = svc.Upsert(context.Background(), CounterWhereUnique{Name: Ptr("a")},
+		CounterCreateInput{Name: "a", Hits: 1}, CounterUpdateInput{Hits: NumberUpdate[int]{Increment: Ptr(5)}})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+`
+
+func TestGenerate_UpsertIncrement(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-upsert")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+model Counter {
+  id   Int    @id @default(autoincrement())
+  name String @unique
+  hits Int
+}
+`
+	generateWithTest(t, tmpDir, schema, "upsert_test.go", upsertIncrementTest)
+	goTest(t, tmpDir)
+}
+
+// createManyTest is run against the generated client: rows are typed create
+// inputs, and a column one row leaves unset takes its default in that row.
+const createManyTest = `package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestCreateMany(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := NewClientFromSession(runtime.NewSession(db)).TaskService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectExec(` + "`" + `INSERT INTO "task" ("note", "status", "title") VALUES ($1, $2, $3), ($4, DEFAULT, $5)` + "`" + `).
+		WithArgs(nil, "done", "a", "x", "b").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	n, err := svc.CreateMany(context.Background(), []TaskCreateInput{
+		{Title: "a", Status: Ptr("done")},
+		{Title: "b", Note: Ptr("x")},
+	})
+	if err != nil {
+		t.Fatalf("CreateMany: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("CreateMany affected %d rows, want 2", n)
+	}
+}
+`
+
+func TestGenerate_CreateMany(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-createmany")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+model Task {
+  id     Int     @id @default(autoincrement())
+  title  String
+  status String  @default("todo")
+  note   String?
+}
+`
+	generateWithTest(t, tmpDir, schema, "createmany_test.go", createManyTest)
+	contents, err := ioutil.ReadFile(filepath.Join(tmpDir, "client.go"))
+	if err != nil {
+		t.Fatalf("failed to read client.go: %v", err)
+	}
+	if want := `func (svc *TaskService) CreateMany(ctx context.Context, data []TaskCreateInput) (int64, error)`; !strings.Contains(string(contents), want) {
+		t.Errorf("client.go does not contain %q", want)
+	}
+	goTest(t, tmpDir)
+}
+
+// blogSchema is the schema the generated client behavior tests run against.
+const blogSchema = `
+model User {
+  id    Int     @id @default(autoincrement())
+  email String  @unique
+  name  String?
+  posts Post[]
+  tags  Tag[]
+}
+
+model Post {
+  id          Int       @id @default(autoincrement())
+  title       String
+  views       Int       @default(0)
+  publishedAt DateTime?
+  authorId    Int
+  author      User      @relation(fields: [authorId], references: [id])
+}
+
+model Tag {
+  id    Int    @id @default(autoincrement())
+  name  String @unique
+  users User[]
+}
+`
+
+// whereFilterTest is run against the generated client: each set filter
+// operator becomes one condition on the quoted column, combined with AND.
+const whereFilterTest = `package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestWhereFilters(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClientFromSession(runtime.NewSession(db))
+	posts, err := client.PostService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := client.UserService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectQuery(` + "`" + `SELECT "id", "title", "views", "publishedat", "authorid" FROM "post" WHERE LOWER("post"."title") NOT IN (LOWER($1), LOWER($2)) AND "post"."title" ILIKE $3 AND "post"."views" <> $4 AND "post"."views" IN ($5, $6) AND "post"."views" >= $7 AND "post"."publishedat" IS NULL` + "`" + `).
+		WithArgs("a", "b", "%go%", 3, 1, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "views", "publishedat", "authorid"}))
+	_, err = posts.FindMany(ctx, PostWhere{
+		Title:       StringFilter{Contains: Ptr("go"), NotIn: []string{"a", "b"}, Mode: ModeInsensitive},
+		Views:       IntFilter{In: []int{1, 2}, Gte: Ptr(1), Not: Ptr(3)},
+		PublishedAt: TimeFilter{IsNull: Ptr(true)},
+	}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("FindMany posts: %v", err)
+	}
+
+	// LIKE wildcards in the value are escaped.
+	mock.ExpectQuery(` + "`" + `SELECT "id", "email", "name" FROM "user" WHERE "user"."email" < $1 AND "user"."email" LIKE $2 AND "user"."name" = $3` + "`" + `).
+		WithArgs("m", "a\\%%", "x").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name"}))
+	_, err = users.FindMany(ctx, UserWhere{
+		Email: StringFilter{StartsWith: Ptr("a%"), Lt: Ptr("m")},
+		Name:  StringFilter{Equals: Ptr("x")},
+	}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("FindMany users: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+`
+
+func TestGenerate_WhereFilters(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-filters")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	generateWithTest(t, tmpDir, blogSchema, "filters_test.go", whereFilterTest)
+	goTest(t, tmpDir)
+}
+
+// logicalFilterTest is run against the generated client: OR elements are
+// parenthesized alternatives and each NOT element is negated on its own.
+const logicalFilterTest = `package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestLogicalFilters(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := NewClientFromSession(runtime.NewSession(db)).UserService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(` + "`" + `SELECT "id", "email", "name" FROM "user" WHERE "user"."email" LIKE $1 AND (("user"."name" = $2) OR ("user"."name" IS NULL)) AND NOT ("user"."id" = $3) AND NOT ("user"."id" = $4)` + "`" + `).
+		WithArgs("%@x.io", "a", 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name"}))
+	_, err = users.FindMany(context.Background(), UserWhere{
+		Email: StringFilter{EndsWith: Ptr("@x.io")},
+		OR:    []UserWhere{{Name: StringFilter{Equals: Ptr("a")}}, {Name: StringFilter{IsNull: Ptr(true)}}},
+		NOT:   []UserWhere{{Id: IntFilter{Equals: Ptr(1)}}, {Id: IntFilter{Equals: Ptr(2)}}},
+	}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("FindMany: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+`
+
+func TestGenerate_LogicalFilters(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-logical")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	generateWithTest(t, tmpDir, blogSchema, "logical_test.go", logicalFilterTest)
+	goTest(t, tmpDir)
+}
+
+// relationFilterTest is run against the generated client: list relation
+// filters are EXISTS subqueries, through the join table for many-to-many
+// relations, and Every matches when no related record fails the filter.
+const relationFilterTest = `package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestRelationFilters(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClientFromSession(runtime.NewSession(db))
+	users, err := client.UserService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, err := client.PostService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectQuery(` + "`" + `SELECT "id", "email", "name" FROM "user" WHERE EXISTS (SELECT 1 FROM "post" r1 WHERE r1."authorid" = "user"."id" AND (r1."views" > $1)) AND NOT EXISTS (SELECT 1 FROM "post" r1 WHERE r1."authorid" = "user"."id" AND NOT (r1."title" LIKE $2)) AND NOT EXISTS (SELECT 1 FROM "tag" r1 JOIN "tag_user" r1j ON r1j."tag_id" = r1."id" WHERE r1j."user_id" = "user"."id" AND (r1."name" = $3))` + "`" + `).
+		WithArgs(10, "%go%", "spam").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name"}))
+	_, err = users.FindMany(ctx, UserWhere{
+		Posts: ListRelationFilter[PostWhere]{
+			Some:  &PostWhere{Views: IntFilter{Gt: Ptr(10)}},
+			Every: &PostWhere{Title: StringFilter{Contains: Ptr("go")}},
+		},
+		Tags: ListRelationFilter[TagWhere]{None: &TagWhere{Name: StringFilter{Equals: Ptr("spam")}}},
+	}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("FindMany users: %v", err)
+	}
+
+	mock.ExpectQuery(` + "`" + `SELECT "id", "title", "views", "publishedat", "authorid" FROM "post" WHERE NOT EXISTS (SELECT 1 FROM "user" r1 WHERE r1."id" = "post"."authorid" AND (r1."email" = $1))` + "`" + `).
+		WithArgs("a@x.io").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "views", "publishedat", "authorid"}))
+	_, err = posts.FindMany(ctx, PostWhere{
+		Author: RelationFilter[UserWhere]{IsNot: &UserWhere{Email: StringFilter{Equals: Ptr("a@x.io")}}},
+	}, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("FindMany posts: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+`
+
+func TestGenerate_RelationFilters(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-relfilters")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	generateWithTest(t, tmpDir, blogSchema, "relation_filters_test.go", relationFilterTest)
+	goTest(t, tmpDir)
+}
+
+// orderByTest is run against the generated client: orderings by a related
+// record's field or by a relation count are correlated subqueries, and each
+// term carries its own direction and NULLS placement.
+const orderByTest = `package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestOrderBy(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClientFromSession(runtime.NewSession(db))
+	posts, err := client.PostService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := client.UserService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectQuery(` + "`" + `SELECT "id", "title", "views", "publishedat", "authorid" FROM "post" ORDER BY (SELECT o1."name" FROM "user" o1 WHERE o1."id" = "post"."authorid") DESC NULLS LAST, "post"."publishedat" NULLS FIRST LIMIT 10 OFFSET 5` + "`" + `).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "views", "publishedat", "authorid"}))
+	_, err = posts.FindMany(ctx, PostWhere{}, []PostOrderBy{
+		{Author: &UserOrderBy{Field: UserFieldName, Dir: Desc, Nulls: Last}},
+		{Field: PostFieldPublishedAt, Nulls: First},
+	}, 5, 10)
+	if err != nil {
+		t.Fatalf("FindMany posts: %v", err)
+	}
+
+	mock.ExpectQuery(` + "`" + `SELECT "id", "email", "name" FROM "user" ORDER BY (SELECT COUNT(*) FROM "post" o1 WHERE o1."authorid" = "user"."id") DESC` + "`" + `).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name"}))
+	_, err = users.FindMany(ctx, UserWhere{}, []UserOrderBy{{Count: UserRelationPosts, Dir: Desc}}, 0, 0)
+	if err != nil {
+		t.Fatalf("FindMany users: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+`
+
+func TestGenerate_OrderBy(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-orderby")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	generateWithTest(t, tmpDir, blogSchema, "orderby_test.go", orderByTest)
+	goTest(t, tmpDir)
+}
+
+// keysetTest is run against the generated client: FindPage continues after
+// the cursor of the previous page, with the NULL placement of each ordering
+// (PostgreSQL puts NULL first in descending order) deciding where NULL
+// cursor values and NULL rows fall.
+const keysetTest = `package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestKeysetPagination(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, err := NewClientFromSession(runtime.NewSession(db)).PostService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	cols := []string{"id", "title", "views", "publishedat", "authorid"}
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(24 * time.Hour)
+
+	newest := []PostOrderBy{{Field: PostFieldPublishedAt, Dir: Desc}}
+	mock.ExpectQuery(` + "`" + `SELECT "id", "title", "views", "publishedat", "authorid" FROM "post" ORDER BY "post"."publishedat" DESC, "post"."id" LIMIT 2` + "`" + `).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, "a", 0, nil, 1).AddRow(2, "b", 0, t1, 1))
+	page, cursor, err := posts.FindPage(ctx, PostWhere{}, newest, 1, "")
+	if err != nil || len(page) != 1 || page[0].Id != 1 || cursor == "" {
+		t.Fatalf("first page = %v, %q, %v", page, cursor, err)
+	}
+	// After a NULL, only the remaining NULL rows and all non-NULL rows follow.
+	// Cursor values are bound as text, which PostgreSQL casts to the column type.
+	mock.ExpectQuery(` + "`" + `SELECT "id", "title", "views", "publishedat", "authorid" FROM "post" WHERE (("post"."publishedat" IS NOT NULL) OR ("post"."publishedat" IS NULL AND "post"."id" > $1)) ORDER BY "post"."publishedat" DESC, "post"."id" LIMIT 2` + "`" + `).
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(2, "b", 0, t1, 1).AddRow(3, "c", 0, t0, 1))
+	page, cursor, err = posts.FindPage(ctx, PostWhere{}, newest, 1, cursor)
+	if err != nil || len(page) != 1 || page[0].Id != 2 || cursor == "" {
+		t.Fatalf("second page = %v, %q, %v", page, cursor, err)
+	}
+	mock.ExpectQuery(` + "`" + `SELECT "id", "title", "views", "publishedat", "authorid" FROM "post" WHERE (("post"."publishedat" < $1) OR ("post"."publishedat" = $2 AND "post"."id" > $3)) ORDER BY "post"."publishedat" DESC, "post"."id" LIMIT 2` + "`" + `).
+		WithArgs("2024-01-02T00:00:00Z", "2024-01-02T00:00:00Z", "2").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(3, "c", 0, t0, 1))
+	page, cursor, err = posts.FindPage(ctx, PostWhere{}, newest, 1, cursor)
+	if err != nil || len(page) != 1 || page[0].Id != 3 || cursor != "" {
+		t.Fatalf("last page = %v, %q, %v", page, cursor, err)
+	}
+
+	// After a non-NULL value in ascending NULLS LAST order, the NULL rows follow.
+	oldest := []PostOrderBy{{Field: PostFieldPublishedAt, Nulls: Last}, {Field: PostFieldViews, Dir: Desc}}
+	mock.ExpectQuery(` + "`" + `SELECT "id", "title", "views", "publishedat", "authorid" FROM "post" ORDER BY "post"."publishedat" NULLS LAST, "post"."views" DESC, "post"."id" LIMIT 2` + "`" + `).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(3, "c", 7, t0, 1).AddRow(2, "b", 0, t1, 1))
+	_, cursor, err = posts.FindPage(ctx, PostWhere{}, oldest, 1, "")
+	if err != nil || cursor == "" {
+		t.Fatalf("first page = %q, %v", cursor, err)
+	}
+	mock.ExpectQuery(` + "`" + `SELECT "id", "title", "views", "publishedat", "authorid" FROM "post" WHERE ((("post"."publishedat" > $1 OR "post"."publishedat" IS NULL)) OR ("post"."publishedat" = $2 AND "post"."views" < $3) OR ("post"."publishedat" = $2 AND "post"."views" = $4 AND "post"."id" > $5)) ORDER BY "post"."publishedat" NULLS LAST, "post"."views" DESC, "post"."id" LIMIT 2` + "`" + `).
+		WithArgs("2024-01-01T00:00:00Z", "2024-01-01T00:00:00Z", "7", "7", "3").
+		WillReturnRows(sqlmock.NewRows(cols))
+	if _, _, err := posts.FindPage(ctx, PostWhere{}, oldest, 1, cursor); err != nil {
+		t.Fatalf("second page: %v", err)
+	}
+
+	// A cursor only continues the ordering it was made for.
+	if _, _, err := posts.FindPage(ctx, PostWhere{}, newest, 1, cursor); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("FindPage with another ordering's cursor = %v, want ErrInvalidCursor", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+`
+
+func TestGenerate_KeysetPagination(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-keyset")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	generateWithTest(t, tmpDir, blogSchema, "keyset_test.go", keysetTest)
+	goTest(t, tmpDir)
+}
+
+// iterTest is run against the generated client: FindManyIter streams rows,
+// closes them when the caller stops early, and yields a failing row's error.
+const iterTest = `package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestFindManyIter(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := NewClientFromSession(runtime.NewSession(db)).UserService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	query := ` + "`" + `SELECT "id", "email", "name" FROM "user" ORDER BY "user"."id"` + "`" + `
+	orderBy := []UserOrderBy{{Field: UserFieldId}}
+
+	mock.ExpectQuery(query).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name"}).AddRow(1, "a@x.io", nil).AddRow(2, "b@x.io", nil).AddRow(3, "c@x.io", nil)).
+		RowsWillBeClosed()
+	var seen []int
+	for u, err := range users.FindManyIter(ctx, UserWhere{}, orderBy, 0, 0) {
+		if err != nil {
+			t.Fatalf("FindManyIter: %v", err)
+		}
+		seen = append(seen, u.Id)
+		if len(seen) == 2 {
+			break
+		}
+	}
+	if len(seen) != 2 || seen[0] != 1 || seen[1] != 2 {
+		t.Errorf("FindManyIter yielded %v, want [1 2]", seen)
+	}
+
+	broken := errors.New("connection reset")
+	mock.ExpectQuery(query).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name"}).AddRow(1, "a@x.io", nil).AddRow(2, "b@x.io", nil).RowError(1, broken)).
+		RowsWillBeClosed()
+	var got error
+	n := 0
+	for _, err := range users.FindManyIter(ctx, UserWhere{}, orderBy, 0, 0) {
+		if err != nil {
+			got = err
+			break
+		}
+		n++
+	}
+	if n != 1 || !errors.Is(got, broken) {
+		t.Errorf("FindManyIter yielded %d users and error %v, want 1 and %v", n, got, broken)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+`
+
+func TestGenerate_FindManyIter(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-iter")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	generateWithTest(t, tmpDir, blogSchema, "iter_test.go", iterTest)
+	goTest(t, tmpDir)
+}
+
+// nestedCreateTest is run against the generated client: nested writes run in
+// one transaction, children take the parent's new key, connected records
+// supply theirs, and a failed nested write rolls the parent back.
+const nestedCreateTest = `package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestNestedCreate(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClientFromSession(runtime.NewSession(db))
+	users, err := client.UserService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, err := client.PostService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	userCols := []string{"id", "email", "name"}
+	postCols := []string{"id", "title", "views", "publishedat", "authorid"}
+	insertUser := ` + "`" + `INSERT INTO "user" ("email", "name") VALUES ($1, $2) RETURNING "id", "email", "name"` + "`" + `
+	insertPost := ` + "`" + `INSERT INTO "post" ("authorid", "publishedat", "title") VALUES ($1, $2, $3) RETURNING "id", "title", "views", "publishedat", "authorid"` + "`" + `
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(insertUser).WithArgs("a@x.io", nil).
+		WillReturnRows(sqlmock.NewRows(userCols).AddRow(4, "a@x.io", nil))
+	mock.ExpectQuery(insertPost).WithArgs(4, nil, "hello").
+		WillReturnRows(sqlmock.NewRows(postCols).AddRow(1, "hello", 0, nil, 4))
+	mock.ExpectCommit()
+	_, err = users.Create(ctx, UserCreateInput{
+		Email: "a@x.io",
+		Posts: ListRelationCreate[PostCreateInput, PostWhereUnique]{Create: []PostCreateInput{{Title: "hello"}}},
+	})
+	if err != nil {
+		t.Fatalf("Create with nested posts: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(` + "`" + `SELECT "id", "email", "name" FROM "user" WHERE "email" = $1 LIMIT 1` + "`" + `).WithArgs("a@x.io").
+		WillReturnRows(sqlmock.NewRows(userCols).AddRow(4, "a@x.io", nil))
+	mock.ExpectQuery(insertPost).WithArgs(4, nil, "again").
+		WillReturnRows(sqlmock.NewRows(postCols).AddRow(2, "again", 0, nil, 4))
+	mock.ExpectCommit()
+	_, err = posts.Create(ctx, PostCreateInput{
+		Title:  "again",
+		Author: RelationCreate[UserCreateInput, UserWhereUnique]{Connect: &UserWhereUnique{Email: Ptr("a@x.io")}},
+	})
+	if err != nil {
+		t.Fatalf("Create connecting author: %v", err)
+	}
+
+	failed := errors.New("insert failed")
+	mock.ExpectBegin()
+	mock.ExpectQuery(insertUser).WithArgs("b@x.io", nil).
+		WillReturnRows(sqlmock.NewRows(userCols).AddRow(5, "b@x.io", nil))
+	mock.ExpectQuery(insertPost).WithArgs(5, nil, "hello").WillReturnError(failed)
+	mock.ExpectRollback()
+	_, err = users.Create(ctx, UserCreateInput{
+		Email: "b@x.io",
+		Posts: ListRelationCreate[PostCreateInput, PostWhereUnique]{Create: []PostCreateInput{{Title: "hello"}}},
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("Create with failing nested post = %v, want %v", err, failed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+`
+
+func TestGenerate_NestedCreate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-nested")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	generateWithTest(t, tmpDir, blogSchema, "nested_test.go", nestedCreateTest)
+	goTest(t, tmpDir)
+}
+
+// joinTableTest is run against the generated client: Connect and Disconnect
+// write the join table in one statement, and Set replaces the links in a
+// transaction.
+const joinTableTest = `package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/TechXTT/TORM/pkg/runtime"
+)
+
+func TestJoinTable(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := NewClientFromSession(runtime.NewSession(db)).UserService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	unlinkAll := ` + "`" + `DELETE FROM "tag_user" WHERE "user_id" = $1` + "`" + `
+
+	mock.ExpectExec(` + "`" + `INSERT INTO "tag_user" ("user_id", "tag_id") VALUES ($1, $2), ($1, $3) ON CONFLICT DO NOTHING` + "`" + `).
+		WithArgs(1, 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	if err := users.ConnectTags(ctx, 1, 2, 3); err != nil {
+		t.Fatalf("ConnectTags: %v", err)
+	}
+
+	mock.ExpectExec(` + "`" + `DELETE FROM "tag_user" WHERE "user_id" = $1 AND "tag_id" IN ($2, $3)` + "`" + `).
+		WithArgs(1, 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	if err := users.DisconnectTags(ctx, 1, 2, 3); err != nil {
+		t.Fatalf("DisconnectTags: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(unlinkAll).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(` + "`" + `INSERT INTO "tag_user" ("user_id", "tag_id") VALUES ($1, $2) ON CONFLICT DO NOTHING` + "`" + `).
+		WithArgs(1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := users.SetTags(ctx, 1, 4); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+
+	// Without ids, Set only removes the links.
+	mock.ExpectBegin()
+	mock.ExpectExec(unlinkAll).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := users.SetTags(ctx, 1); err != nil {
+		t.Fatalf("SetTags without ids: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+`
+
+func TestGenerate_JoinTable(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-jointable")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	generateWithTest(t, tmpDir, blogSchema, "join_table_test.go", joinTableTest)
+	goTest(t, tmpDir)
+}
+
+func TestGenerate_OptionalTypesOption(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "torm-gen-sqlnull")
+	if err != nil {
+		t.Fatalf("failed to create tmp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+generator client {
+  provider      = "torm"
+  optionalTypes = "sqlnull"
+}
+
+model Note {
+  id       Int       @id @default(autoincrement())
+  body     String?
+  editedAt DateTime?
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	writeGoMod(t, tmpDir)
+
+	if err := Generate(schemaPath, tmpDir); err != nil {
+		t.Fatalf("Generate() error: %v", err)
+	}
+	noteContents, err := ioutil.ReadFile(filepath.Join(tmpDir, "note.go"))
+	if err != nil {
+		t.Fatalf("failed to read note.go: %v", err)
+	}
+	for _, want := range []string{
+		`"database/sql"`,
+		`Body\s+sql\.Null\[string\]`,
+		`EditedAt\s+sql\.Null\[time\.Time\]`,
+	} {
+		if !regexp.MustCompile(want).Match(noteContents) {
+			t.Errorf("note.go does not match %q; got:\n%s", want, string(noteContents))
+		}
+	}
+
+	// Unknown option values are rejected
+	bad := strings.Replace(schema, `"sqlnull"`, `"nullable"`, 1)
+	if err := ioutil.WriteFile(schemaPath, []byte(bad), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	if err := Generate(schemaPath, tmpDir); err == nil || !strings.Contains(err.Error(), "optionalTypes") {
+		t.Errorf("expected optionalTypes error, got %v", err)
+	}
 }
@@ -0,0 +1,258 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pos is a 1-based line and column position in a schema file.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// ParseError reports a schema error at a specific position, formatted as
+// "file:line:col: message".
+type ParseError struct {
+	File string
+	Pos  Pos
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Pos.Line, e.Pos.Col, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNewline
+	tokIdent
+	tokString
+	tokNumber
+	tokLBrace
+	tokRBrace
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokColon
+	tokEquals
+	tokQuestion
+	tokDot
+	tokAt
+	tokAtAt
+)
+
+var tokenNames = map[tokenKind]string{
+	tokEOF:      "end of file",
+	tokNewline:  "newline",
+	tokIdent:    "identifier",
+	tokString:   "string",
+	tokNumber:   "number",
+	tokLBrace:   `"{"`,
+	tokRBrace:   `"}"`,
+	tokLParen:   `"("`,
+	tokRParen:   `")"`,
+	tokLBracket: `"["`,
+	tokRBracket: `"]"`,
+	tokComma:    `","`,
+	tokColon:    `":"`,
+	tokEquals:   `"="`,
+	tokQuestion: `"?"`,
+	tokDot:      `"."`,
+	tokAt:       `"@"`,
+	tokAtAt:     `"@@"`,
+}
+
+func (k tokenKind) String() string {
+	return tokenNames[k]
+}
+
+// punctuation maps single-character tokens to their kinds.
+var punctuation = map[byte]tokenKind{
+	'\n': tokNewline,
+	'{':  tokLBrace,
+	'}':  tokRBrace,
+	'(':  tokLParen,
+	')':  tokRParen,
+	'[':  tokLBracket,
+	']':  tokRBracket,
+	',':  tokComma,
+	':':  tokColon,
+	'=':  tokEquals,
+	'?':  tokQuestion,
+	'.':  tokDot,
+}
+
+// token is a single lexical element. For strings, text holds the unquoted value.
+type token struct {
+	kind tokenKind
+	text string
+	pos  Pos
+}
+
+// describe renders a token for use in error messages.
+func (t token) describe() string {
+	switch t.kind {
+	case tokIdent, tokNumber:
+		return fmt.Sprintf("%q", t.text)
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return t.kind.String()
+	}
+}
+
+// lexer splits a Prisma schema into tokens. Comments are dropped, newlines are
+// kept because field declarations are line-oriented.
+type lexer struct {
+	file string
+	src  string
+	off  int
+	line int
+	col  int
+}
+
+func newLexer(file string, src []byte) *lexer {
+	return &lexer{file: file, src: string(src), line: 1, col: 1}
+}
+
+func (l *lexer) errorf(pos Pos, format string, args ...interface{}) error {
+	return &ParseError{File: l.file, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) peekByte(n int) byte {
+	if l.off+n < len(l.src) {
+		return l.src[l.off+n]
+	}
+	return 0
+}
+
+func (l *lexer) advance() byte {
+	c := l.src[l.off]
+	l.off++
+	if c == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return c
+}
+
+// tokenize returns every token in the source, terminated by tokEOF.
+func (l *lexer) tokenize() ([]token, error) {
+	var toks []token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		toks = append(toks, tok)
+		if tok.kind == tokEOF {
+			return toks, nil
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.advance()
+		case c == '/' && l.peekByte(1) == '/':
+			for l.off < len(l.src) && l.src[l.off] != '\n' {
+				l.advance()
+			}
+		default:
+			return l.scan()
+		}
+	}
+	return token{kind: tokEOF, pos: Pos{l.line, l.col}}, nil
+}
+
+func (l *lexer) scan() (token, error) {
+	pos := Pos{l.line, l.col}
+	c := l.src[l.off]
+	if kind, ok := punctuation[c]; ok {
+		l.advance()
+		return token{kind: kind, text: string(c), pos: pos}, nil
+	}
+	switch {
+	case c == '@':
+		l.advance()
+		if l.peekByte(0) == '@' {
+			l.advance()
+			return token{kind: tokAtAt, text: "@@", pos: pos}, nil
+		}
+		return token{kind: tokAt, text: "@", pos: pos}, nil
+	case c == '"':
+		return l.scanString(pos)
+	case isDigit(c) || (c == '-' && isDigit(l.peekByte(1))):
+		start := l.off
+		l.advance()
+		for l.off < len(l.src) && (isDigit(l.src[l.off]) || l.src[l.off] == '.') {
+			l.advance()
+		}
+		return token{kind: tokNumber, text: l.src[start:l.off], pos: pos}, nil
+	case isIdentStart(c):
+		start := l.off
+		for l.off < len(l.src) && isIdentPart(l.src[l.off]) {
+			l.advance()
+		}
+		return token{kind: tokIdent, text: l.src[start:l.off], pos: pos}, nil
+	}
+	return token{}, l.errorf(pos, "unexpected character %q", c)
+}
+
+func (l *lexer) scanString(pos Pos) (token, error) {
+	l.advance() // opening quote
+	var sb strings.Builder
+	for {
+		if l.off >= len(l.src) || l.src[l.off] == '\n' {
+			return token{}, l.errorf(pos, "unterminated string")
+		}
+		c := l.advance()
+		switch c {
+		case '"':
+			return token{kind: tokString, text: sb.String(), pos: pos}, nil
+		case '\\':
+			if l.off >= len(l.src) {
+				return token{}, l.errorf(pos, "unterminated string")
+			}
+			esc := l.advance()
+			switch esc {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case '"', '\\':
+				sb.WriteByte(esc)
+			default:
+				return token{}, l.errorf(Pos{l.line, l.col - 2}, "unknown escape sequence \\%c", esc)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
@@ -2,257 +2,846 @@ package generator
 
 import (
 	"errors"
-	"regexp"
+	"fmt"
+	"os"
 	"strings"
+
+	"github.com/TechXTT/TORM/pkg/internal/typeconv"
 )
 
 // Index describes a model-level index on one or more fields.
 type Index struct {
 	Fields []string // field names in the index
+	Pos    Pos
 }
 
 // Enum describes a Prisma enum type with possible values.
 type Enum struct {
 	Name   string
 	Values []string
+	Pos    Pos
 }
 
 // Field describes a single model field, including metadata for migration generation.
 type Field struct {
 	Name          string   // Go struct field name
+	Column        string   // Database column name: the @map name as written, or the lowercased field name
 	Type          string   // Go type (e.g., "string", "int", "time.Time", "uuid.UUID")
-	Default       *string  // Default value expression, if any
+	DBType        string   // SQL column type (e.g., "TEXT", "BIGINT", "NUMERIC")
+	Default       *string  // SQL default expression, if any
 	NotNull       bool     // True if the field is required (no null)
 	PrimaryKey    bool     // True if this field is a primary key
+	Unique        bool     // True if this field has a @unique constraint
 	AutoIncrement bool     // True if this field uses auto-increment (serial)
+	UpdatedAt     bool     // True if the field is set to the current time on every write (@updatedAt)
 	EnumValues    []string // List of enum options, if the field is an enum
+	Pos           Pos      // Position of the field declaration
 }
 
-// Relation describes a list‐based relation field (e.g., votesAsNetwork Vote[]).
+// Relation describes a relation field, either a list (e.g. votesAsNetwork Vote[])
+// or a singular reference (e.g. author Creator @relation(fields: [authorId], references: [id])).
 type Relation struct {
-	Name string // Go struct field name (from schema line, e.g. "votesAsNetwork")
-	Type string // Target model name (e.g. "Vote")
-	JoinTableName string // new field for many-to-many join table name
+	Name          string   // Go struct field name (from schema line, e.g. "votesAsNetwork")
+	Type          string   // Target model name (e.g. "Vote")
+	List          bool     // True for list relations (Vote[])
+	Optional      bool     // True for optional singular relations (Creator?)
+	RelationName  string   // Relation name from @relation("name"), if any
+	Fields        []string // Foreign key fields on this model, set on the owning side
+	References    []string // Referenced fields on the target model
+	OnDelete      string   // Referential action, e.g. "Cascade"; empty for the database default
+	OnUpdate      string   // Referential action on update
+	ConstraintMap string   // Foreign key constraint name from @relation(map: "..."), if any
+	JoinTableName string   // new field for many-to-many join table name
+	JoinColumn    string   // join table column referencing this model, for many-to-many relations
+	JoinTarget    string   // join table column referencing the target model
+	Pos           Pos      // Position of the relation field declaration
 }
 
 // Entity describes a model.
 type Entity struct {
-	Name      string
-	Fields    []Field
-	Indexes   []Index    // added to capture @@index definitions
-	Relations []Relation // list of related model relations (fieldName + target type)
+	Name              string
+	Table             string // Database table name: the @@map name as written, or the lowercased model name
+	Fields            []Field
+	Indexes           []Index    // added to capture @@index definitions
+	UniqueConstraints []Index    // multi-field @@unique definitions
+	PrimaryKey        []string   // fields of a multi-field @@id primary key
+	Relations         []Relation // relation fields, both lists and singular references
+	Pos               Pos        // Position of the model name
+}
+
+// Generator describes a generator block; Options holds its string-valued settings.
+type Generator struct {
+	Name     string
+	Provider string
+	Options  map[string]string
+	Pos      Pos
+}
+
+// Datasource describes a datasource block.
+type Datasource struct {
+	Name     string
+	Provider string
+	Pos      Pos
 }
 
 // AST is the parsed schema representation.
 type AST struct {
-	Enums    []Enum
-	Entities []Entity
+	Enums       []Enum
+	Entities    []Entity
+	Generators  []Generator
+	Datasources []Datasource
+}
+
+// scalarTypes maps Prisma scalar types to Go types.
+var scalarTypes = map[string]string{
+	"String":   "string",
+	"Int":      "int",
+	"BigInt":   "int64",
+	"Float":    "float64",
+	"Decimal":  "float64",
+	"Boolean":  "bool",
+	"DateTime": "time.Time",
+	"Json":     "json.RawMessage",
+	"Bytes":    "[]byte",
+}
+
+// scalarSQLTypes holds the column types of scalars that share a Go type with
+// another scalar but not its SQL type: Decimal is a float64 stored exactly.
+var scalarSQLTypes = map[string]string{
+	"Decimal": "NUMERIC",
+}
+
+// ParseSchemaFile reads and parses the Prisma schema at path. Errors are
+// reported relative to path.
+func ParseSchemaFile(path string) (AST, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AST{}, err
+	}
+	return parseSchema(path, data)
 }
 
 // ParseSchema parses a Prisma schema into an AST.
 func ParseSchema(input []byte) (AST, error) {
-	schema := string(input)
+	return parseSchema("schema.prisma", input)
+}
 
+func parseSchema(file string, input []byte) (AST, error) {
+	toks, err := newLexer(file, input).tokenize()
+	if err != nil {
+		return AST{}, err
+	}
+	p := &parser{file: file, toks: toks}
+	blocks, err := p.parseFile()
+	if err != nil {
+		return AST{}, err
+	}
+	r := &resolver{file: file, models: map[string]*blockDecl{}, enums: map[string]*blockDecl{}}
+	return r.resolve(blocks)
+}
+
+// resolver turns parsed blocks into the AST, validating names, types and attributes.
+type resolver struct {
+	file   string
+	models map[string]*blockDecl
+	enums  map[string]*blockDecl
+}
+
+func (r *resolver) errorf(pos Pos, format string, args ...interface{}) error {
+	return &ParseError{File: r.file, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (r *resolver) resolve(blocks []*blockDecl) (AST, error) {
 	var ast AST
 
-	// First, parse enum blocks
-	enumRe := regexp.MustCompile(`enum\s+(\w+)\s*{([^}]*)}`)
-	enumMatches := enumRe.FindAllStringSubmatch(schema, -1)
-	for _, em := range enumMatches {
-		enumName := em[1]
-		block := em[2]
-		lines := strings.Split(block, "\n")
-		var values []string
-		for _, line := range lines {
-			val := strings.TrimSpace(line)
-			if val == "" {
-				continue
+	// Register every model and enum first so types can be referenced before
+	// they are declared.
+	for _, b := range blocks {
+		if b.kind != "model" && b.kind != "enum" {
+			continue
+		}
+		if _, dup := r.models[b.name]; dup {
+			return AST{}, r.errorf(b.pos, "%q is already defined", b.name)
+		}
+		if _, dup := r.enums[b.name]; dup {
+			return AST{}, r.errorf(b.pos, "%q is already defined", b.name)
+		}
+		if _, scalar := scalarTypes[b.name]; scalar {
+			return AST{}, r.errorf(b.pos, "%q is a reserved scalar type name", b.name)
+		}
+		if b.kind == "model" {
+			r.models[b.name] = b
+		} else {
+			r.enums[b.name] = b
+		}
+	}
+
+	for _, b := range blocks {
+		if b.kind != "datasource" {
+			continue
+		}
+		ds := Datasource{Name: b.name, Pos: b.pos}
+		for _, prop := range b.props {
+			if prop.name == "provider" && prop.value.kind == exprString {
+				ds.Provider = prop.value.text
 			}
-			values = append(values, val)
 		}
-		ast.Enums = append(ast.Enums, Enum{
-			Name:   enumName,
-			Values: values,
-		})
+		ast.Datasources = append(ast.Datasources, ds)
 	}
 
-	// Remove enum blocks from schema to avoid parsing them as models
-	schemaWithoutEnums := enumRe.ReplaceAllString(schema, "")
+	for _, b := range blocks {
+		if b.kind != "generator" {
+			continue
+		}
+		gen := Generator{Name: b.name, Options: map[string]string{}, Pos: b.pos}
+		for _, prop := range b.props {
+			if prop.value.kind == exprString {
+				gen.Options[prop.name] = prop.value.text
+			}
+		}
+		gen.Provider = gen.Options["provider"]
+		ast.Generators = append(ast.Generators, gen)
+	}
 
-	modelRe := regexp.MustCompile(`model\s+(\w+)\s*{([^}]*)}`)
-	matches := modelRe.FindAllStringSubmatch(schemaWithoutEnums, -1)
-	if len(matches) == 0 {
+	for _, b := range blocks {
+		if b.kind != "enum" {
+			continue
+		}
+		enum, err := r.resolveEnum(b)
+		if err != nil {
+			return AST{}, err
+		}
+		ast.Enums = append(ast.Enums, enum)
+	}
+
+	for _, b := range blocks {
+		if b.kind != "model" {
+			continue
+		}
+		ent, err := r.resolveModel(b)
+		if err != nil {
+			return AST{}, err
+		}
+		for _, other := range ast.Entities {
+			if other.Table == ent.Table {
+				return AST{}, r.errorf(ent.Pos, "model %s maps to table %q of model %s", ent.Name, ent.Table, other.Name)
+			}
+		}
+		ast.Entities = append(ast.Entities, ent)
+	}
+	if len(ast.Entities) == 0 {
 		return AST{}, errors.New("no model definitions found")
 	}
+	if err := r.checkBackRelations(ast); err != nil {
+		return AST{}, err
+	}
+
+	assignJoinTables(&ast)
+	return ast, nil
+}
+
+func (r *resolver) resolveEnum(b *blockDecl) (Enum, error) {
+	enum := Enum{Name: b.name, Pos: b.pos}
+	seen := map[string]bool{}
+	for _, v := range b.values {
+		if seen[v.name] {
+			return Enum{}, r.errorf(v.pos, "duplicate value %q in enum %s", v.name, b.name)
+		}
+		seen[v.name] = true
+		for _, attr := range v.attrs {
+			if attr.name != "map" {
+				return Enum{}, r.errorf(attr.pos, "unknown attribute %s", attr.displayName())
+			}
+		}
+		enum.Values = append(enum.Values, v.name)
+	}
+	for _, attr := range b.attrs {
+		if attr.name != "map" {
+			return Enum{}, r.errorf(attr.pos, "unknown attribute %s", attr.displayName())
+		}
+	}
+	if len(enum.Values) == 0 {
+		return Enum{}, r.errorf(b.pos, "enum %s has no values", b.name)
+	}
+	return enum, nil
+}
+
+func (r *resolver) resolveModel(b *blockDecl) (Entity, error) {
+	ent := Entity{Name: b.name, Table: strings.ToLower(b.name), Pos: b.pos}
+	fieldNames := map[string]bool{}
+
+	for _, fd := range b.fields {
+		if fieldNames[fd.name] {
+			return Entity{}, r.errorf(fd.pos, "duplicate field %q in model %s", fd.name, b.name)
+		}
+		fieldNames[fd.name] = true
+		if err := r.checkAttributes(fd.attrs); err != nil {
+			return Entity{}, err
+		}
 
-	for _, m := range matches {
-		name := m[1]
-		block := m[2]
-		lines := strings.Split(block, "\n")
-
-		var indexes []Index
-		// First pass: capture any @@index(...) definitions
-		indexRe := regexp.MustCompile(`^\s*@@index\(\[([^\]]+)\]\)`)
-		for _, line := range lines {
-			trimmed := strings.TrimSpace(line)
-			if m := indexRe.FindStringSubmatch(trimmed); m != nil {
-				// m[1] contains comma-separated field names
-				cols := strings.Split(m[1], ",")
-				for i := range cols {
-					cols[i] = strings.TrimSpace(strings.Trim(cols[i], `"`))
+		if _, isModel := r.models[fd.typeName]; isModel {
+			for _, a := range fd.attrs {
+				if a.name != "relation" {
+					return Entity{}, r.errorf(a.pos, "attribute %s is not valid on relation field %q", a.displayName(), fd.name)
 				}
-				indexes = append(indexes, Index{Fields: cols})
 			}
+			// Relation fields hold no column of their own; foreign keys are
+			// declared as separate scalar fields.
+			rel, err := r.resolveRelation(b, fd)
+			if err != nil {
+				return Entity{}, err
+			}
+			ent.Relations = append(ent.Relations, rel)
+			continue
 		}
 
-		var fields []Field
-		var relations []Relation // accumulate list-based relations for this entity
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line == "" || strings.HasPrefix(line, "//") {
-				continue
+		f, err := r.resolveField(fd)
+		if err != nil {
+			return Entity{}, err
+		}
+		for _, other := range ent.Fields {
+			if other.Column == f.Column {
+				return Entity{}, r.errorf(fd.pos, "field %q maps to column %q of field %q", f.Name, f.Column, other.Name)
 			}
-			// Skip model-level index lines (already handled)
-			if indexRe.MatchString(line) {
-				continue
+		}
+		ent.Fields = append(ent.Fields, f)
+	}
+
+	// Block attributes may only reference scalar fields.
+	scalarNames := map[string]bool{}
+	for _, f := range ent.Fields {
+		scalarNames[f.Name] = true
+	}
+	for _, attr := range b.attrs {
+		switch attr.name {
+		case "index":
+			fields, err := r.fieldList(attr, scalarNames)
+			if err != nil {
+				return Entity{}, err
 			}
-			parts := strings.Fields(line)
-			if len(parts) < 2 {
-				continue
+			ent.Indexes = append(ent.Indexes, Index{Fields: fields, Pos: attr.pos})
+		case "unique":
+			fields, err := r.fieldList(attr, scalarNames)
+			if err != nil {
+				return Entity{}, err
 			}
-			fname := parts[0]
-			ptype := parts[1]
-			var goType string
-			switch {
-			case strings.HasPrefix(ptype, "String"):
-				goType = "string"
-			case strings.HasPrefix(ptype, "Int"):
-				goType = "int"
-			case strings.HasPrefix(ptype, "Float"):
-				goType = "float64"
-			case strings.HasPrefix(ptype, "Boolean"):
-				goType = "bool"
-			case strings.HasPrefix(ptype, "DateTime"):
-				goType = "time.Time"
-			case strings.HasPrefix(ptype, "Json"):
-				goType = "map[string]interface{}"
-			default:
-				// If ptype matches an enum name, use the enum Go type
-				foundEnum := false
-				for _, enum := range ast.Enums {
-					if ptype == enum.Name {
-						goType = enum.Name
-						foundEnum = true
-						break
+			if len(fields) == 1 {
+				// A single-field @@unique is the same as @unique on that field.
+				for i := range ent.Fields {
+					if ent.Fields[i].Name == fields[0] {
+						ent.Fields[i].Unique = true
 					}
 				}
-				if !foundEnum {
-					goType = ptype
+				continue
+			}
+			ent.UniqueConstraints = append(ent.UniqueConstraints, Index{Fields: fields, Pos: attr.pos})
+		case "id":
+			fields, err := r.fieldList(attr, scalarNames)
+			if err != nil {
+				return Entity{}, err
+			}
+			if ent.PrimaryKey != nil || len(primaryKeys(ent)) > 0 {
+				return Entity{}, r.errorf(attr.pos, "model %s already has a primary key", ent.Name)
+			}
+			for _, name := range fields {
+				for _, f := range ent.Fields {
+					if f.Name == name && !f.NotNull {
+						return Entity{}, r.errorf(attr.pos, "@@id field %q must not be optional", name)
+					}
 				}
 			}
-			f := Field{
-				Name: fname,
-				Type: goType,
-			}
-			// Nullability: optional if type ends with '?'
-			if strings.HasSuffix(ptype, "?") {
-				f.Default = nil
-			}
-			// Primary key: @id
-			if strings.Contains(line, "@id") {
-				f.PrimaryKey = true
-			}
-			// Default and AutoIncrement: @default(expr)
-			if idx := strings.Index(line, "@default("); idx >= 0 {
-				expr := line[idx+len("@default("):]
-				if end := strings.LastIndex(expr, ")"); end >= 0 {
-					def := expr[:end]
-					if def == "autoincrement()" {
-						f.AutoIncrement = true
-					} else {
-						f.Default = &def
+			if len(fields) == 1 {
+				// A single-field @@id is the same as @id on that field.
+				for i := range ent.Fields {
+					if ent.Fields[i].Name == fields[0] {
+						ent.Fields[i].PrimaryKey = true
 					}
 				}
+				continue
 			}
-			// Handle PostgreSQL UUID annotation: @db.Uuid
-			if strings.Contains(line, "@db.Uuid") {
-				f.Type = "uuid.UUID"
+			ent.PrimaryKey = fields
+		case "map":
+			if err := r.singleArg(attr, exprString); err != nil {
+				return Entity{}, err
+			}
+			ent.Table = attr.args[0].value.text
+		default:
+			return Entity{}, r.errorf(attr.pos, "unknown attribute %s", attr.displayName())
+		}
+	}
+	return ent, nil
+}
+
+// referentialActions are the accepted onDelete/onUpdate values.
+var referentialActions = map[string]bool{
+	"Cascade":    true,
+	"Restrict":   true,
+	"NoAction":   true,
+	"SetNull":    true,
+	"SetDefault": true,
+}
+
+// resolveRelation converts a relation field of model b into a Relation,
+// validating the @relation arguments against both models.
+func (r *resolver) resolveRelation(b *blockDecl, fd *fieldDecl) (Relation, error) {
+	rel := Relation{Name: fd.name, Type: fd.typeName, List: fd.list, Optional: fd.optional, Pos: fd.pos}
+	attr := findAttr(fd.attrs, "relation")
+	if attr == nil {
+		return rel, nil
+	}
+	var fieldsArg, refsArg *argument
+	for _, arg := range attr.args {
+		switch arg.name {
+		case "", "name":
+			if arg.value.kind != exprString {
+				return Relation{}, r.errorf(arg.pos, "relation name must be a string")
+			}
+			rel.RelationName = arg.value.text
+		case "fields":
+			fieldsArg = arg
+		case "references":
+			refsArg = arg
+		case "onDelete", "onUpdate":
+			if arg.value.kind != exprIdent || !referentialActions[arg.value.text] {
+				return Relation{}, r.errorf(arg.pos, "invalid %s action %s, expected Cascade, Restrict, NoAction, SetNull or SetDefault", arg.name, arg.value.String())
+			}
+			if arg.name == "onDelete" {
+				rel.OnDelete = arg.value.text
+			} else {
+				rel.OnUpdate = arg.value.text
+			}
+		case "map":
+			if arg.value.kind != exprString {
+				return Relation{}, r.errorf(arg.pos, "constraint name must be a string")
+			}
+			rel.ConstraintMap = arg.value.text
+		default:
+			return Relation{}, r.errorf(arg.pos, "unknown argument %q in @relation", arg.name)
+		}
+	}
+	if fieldsArg == nil && refsArg == nil {
+		if rel.OnDelete != "" || rel.OnUpdate != "" || rel.ConstraintMap != "" {
+			return Relation{}, r.errorf(attr.pos, "referential actions require fields and references")
+		}
+		return rel, nil
+	}
+	if fd.list {
+		return Relation{}, r.errorf(attr.pos, "fields and references must be declared on the singular side of relation %q", fd.name)
+	}
+	if fieldsArg == nil || refsArg == nil {
+		return Relation{}, r.errorf(attr.pos, "@relation requires both fields and references")
+	}
+
+	local, err := r.relationFields(fieldsArg, b)
+	if err != nil {
+		return Relation{}, err
+	}
+	target := r.models[fd.typeName]
+	remote, err := r.relationFields(refsArg, target)
+	if err != nil {
+		return Relation{}, err
+	}
+	if len(local) != len(remote) {
+		return Relation{}, r.errorf(refsArg.pos, "@relation has %d fields but %d references", len(local), len(remote))
+	}
+	for i := range local {
+		if local[i].Type != remote[i].Type {
+			return Relation{}, r.errorf(fieldsArg.value.items[i].pos, "field %q has type %s but references %s.%s of type %s",
+				local[i].Name, local[i].Type, target.name, remote[i].Name, remote[i].Type)
+		}
+		if !local[i].NotNull && !fd.optional {
+			return Relation{}, r.errorf(fd.pos, "relation %q is required but its field %q is optional; mark the relation optional with %s?", fd.name, local[i].Name, fd.typeName)
+		}
+		if local[i].NotNull && (rel.OnDelete == "SetNull" || rel.OnUpdate == "SetNull") {
+			return Relation{}, r.errorf(attr.pos, "SetNull requires field %q to be optional", local[i].Name)
+		}
+		rel.Fields = append(rel.Fields, local[i].Name)
+		rel.References = append(rel.References, remote[i].Name)
+	}
+	if !isUniqueKey(target, rel.References) {
+		return Relation{}, r.errorf(refsArg.pos, "references of relation %q must be the primary key or a unique field of %s", fd.name, target.name)
+	}
+	return rel, nil
+}
+
+// relationFields resolves the scalar fields of model b listed in a
+// fields: or references: argument.
+func (r *resolver) relationFields(arg *argument, b *blockDecl) ([]Field, error) {
+	if arg.value.kind != exprArray || len(arg.value.items) == 0 {
+		return nil, r.errorf(arg.pos, "%s must be a list of fields, e.g. %s: [id]", arg.name, arg.name)
+	}
+	var fields []Field
+	for _, it := range arg.value.items {
+		var decl *fieldDecl
+		for _, fd := range b.fields {
+			if fd.name == it.text {
+				decl = fd
 			}
+		}
+		if it.kind != exprIdent || decl == nil {
+			return nil, r.errorf(it.pos, "unknown field %s in %s of model %s", it.String(), arg.name, b.name)
+		}
+		if _, isModel := r.models[decl.typeName]; isModel || decl.list {
+			return nil, r.errorf(it.pos, "field %q in %s must be a scalar field", decl.name, arg.name)
+		}
+		f, err := r.resolveField(decl)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
 
-			// Handle @updatedAt like not null with default now()
-			if strings.Contains(line, "@updatedAt") {
-				f.NotNull = true
-				if f.Default == nil {
-					now := "now()"
-					f.Default = &now
+// isUniqueKey reports whether fields form the primary key, a unique field
+// or a @@unique constraint of model b.
+func isUniqueKey(b *blockDecl, fields []string) bool {
+	if len(fields) == 1 {
+		for _, fd := range b.fields {
+			if fd.name == fields[0] && (findAttr(fd.attrs, "id") != nil || findAttr(fd.attrs, "unique") != nil) {
+				return true
+			}
+		}
+	}
+	for _, attr := range b.attrs {
+		if attr.name != "unique" && attr.name != "id" {
+			continue
+		}
+		for _, arg := range attr.args {
+			if (arg.name != "" && arg.name != "fields") || arg.value.kind != exprArray || len(arg.value.items) != len(fields) {
+				continue
+			}
+			match := true
+			for i, it := range arg.value.items {
+				match = match && it.text == fields[i]
+			}
+			if match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkBackRelations requires every list relation to have an opposite
+// relation field, and every singular relation without fields to be the back
+// side of a relation whose other side declares them.
+func (r *resolver) checkBackRelations(ast AST) error {
+	for _, ent := range ast.Entities {
+		for _, rel := range ent.Relations {
+			if n := len(candidateRelations(ast.Entities, ent.Name, rel)); n > 1 {
+				return r.errorf(rel.Pos, "relation %q is ambiguous: %s has %d matching relation fields; name both sides with @relation(\"name\")", rel.Name, rel.Type, n)
+			}
+			if len(rel.Fields) > 0 {
+				continue
+			}
+			opp := oppositeRelation(ast.Entities, ent.Name, rel)
+			if rel.List {
+				if opp == nil {
+					return r.errorf(rel.Pos, "relation %q has no matching relation field on model %s", rel.Name, rel.Type)
+				}
+				if opp.List {
+					// Join tables reference a single key column on each side.
+					for _, name := range []string{ent.Name, rel.Type} {
+						if len(primaryKeys(entityByName(ast.Entities, name))) != 1 {
+							return r.errorf(rel.Pos, "many-to-many relation %q requires model %s to have a single @id field", rel.Name, name)
+						}
+					}
 				}
+				continue
+			}
+			if opp == nil || len(opp.Fields) == 0 {
+				return r.errorf(rel.Pos, "relation %q must declare fields and references, e.g. @relation(fields: [%sId], references: [id])", rel.Name, rel.Name)
 			}
+		}
+	}
+	return nil
+}
 
-			// Detect list-based relation fields, e.g. "votesAsNetwork Vote[]"
-			if strings.HasSuffix(ptype, "[]") {
-				// Base type is the model name without the "[]"
-				base := strings.TrimSuffix(ptype, "[]")
-				// Record a list relation field with field name fname and target base
-				relations = append(relations, Relation{
-					Name: fname,
-					Type: base,
-				})
+// oppositeRelation returns the relation field on the target model that forms
+// the other side of rel, declared on model, or nil if there is none.
+func oppositeRelation(ents []Entity, model string, rel Relation) *Relation {
+	if c := candidateRelations(ents, model, rel); len(c) > 0 {
+		return c[0]
+	}
+	return nil
+}
+
+// candidateRelations returns the relation fields on the target model that
+// could form the other side of rel: those pointing back to model with the
+// same relation name.
+func candidateRelations(ents []Entity, model string, rel Relation) []*Relation {
+	var out []*Relation
+	for i := range ents {
+		if ents[i].Name != rel.Type {
+			continue
+		}
+		for j := range ents[i].Relations {
+			other := &ents[i].Relations[j]
+			if other.Type != model || other.RelationName != rel.RelationName {
 				continue
 			}
-			// Skip explicit @relation annotations (handled via list fields)
-			if strings.Contains(line, "@relation") {
+			// A self-relation's opposite is a different field.
+			if model == rel.Type && other.Name == rel.Name {
 				continue
 			}
+			out = append(out, other)
+		}
+	}
+	return out
+}
 
-			fields = append(fields, f)
+// resolveField converts a scalar or enum field declaration into a Field.
+func (r *resolver) resolveField(fd *fieldDecl) (Field, error) {
+	var goType string
+	var enumValues []string
+	if t, ok := scalarTypes[fd.typeName]; ok {
+		goType = t
+	} else if _, ok := r.enums[fd.typeName]; ok {
+		goType = fd.typeName
+		for _, v := range r.enums[fd.typeName].values {
+			enumValues = append(enumValues, v.name)
 		}
+	} else {
+		return Field{}, r.errorf(fd.typePos, "unknown type %q", fd.typeName)
+	}
+	if fd.list {
+		return Field{}, r.errorf(fd.typePos, "scalar list %s[] is not supported", fd.typeName)
+	}
 
-		// After building 'fields' slice:
-		ent := Entity{
-			Name:      name,
-			Fields:    fields,
-			Indexes:   indexes, // assign parsed indexes
-			Relations: relations,
+	// Fields are required unless marked optional with '?'
+	f := Field{Name: fd.name, Column: strings.ToLower(fd.name), Type: goType, NotNull: !fd.optional, EnumValues: enumValues, Pos: fd.pos}
+	for _, attr := range fd.attrs {
+		switch attr.name {
+		case "id":
+			if len(attr.args) > 0 {
+				return Field{}, r.errorf(attr.pos, "@id does not take arguments")
+			}
+			f.PrimaryKey = true
+		case "unique":
+			if len(attr.args) > 0 {
+				return Field{}, r.errorf(attr.pos, "@unique does not take arguments")
+			}
+			f.Unique = true
+		case "default":
+			if len(attr.args) != 1 || attr.args[0].name != "" {
+				return Field{}, r.errorf(attr.pos, "@default requires exactly one value")
+			}
+			val := attr.args[0].value
+			if val.kind == exprCall && val.text == "autoincrement" {
+				if fd.typeName != "Int" && fd.typeName != "BigInt" {
+					return Field{}, r.errorf(val.pos, "autoincrement() requires an Int or BigInt field, %q has type %s", fd.name, fd.typeName)
+				}
+				f.AutoIncrement = true
+			} else {
+				def, err := r.defaultSQL(fd, enumValues, val)
+				if err != nil {
+					return Field{}, err
+				}
+				f.Default = &def
+			}
+		case "map":
+			if err := r.singleArg(attr, exprString); err != nil {
+				return Field{}, err
+			}
+			f.Column = attr.args[0].value.text
+		case "relation":
+			return Field{}, r.errorf(attr.pos, "@relation is only valid on relation fields, %q has type %s", fd.name, fd.typeName)
+		case "db.Uuid":
+			// Handle PostgreSQL UUID annotation: @db.Uuid
+			f.Type = "uuid.UUID"
 		}
-		ast.Entities = append(ast.Entities, ent)
 	}
+	f.DBType = typeconv.MapGoTypeToSQL(f.Type)
+	if t, ok := scalarSQLTypes[fd.typeName]; ok {
+		f.DBType = t
+	}
+	// Handle @updatedAt with a default of now(); generated writes set it too
+	if findAttr(fd.attrs, "updatedAt") != nil {
+		f.UpdatedAt = true
+		if f.Default == nil {
+			now := "CURRENT_TIMESTAMP"
+			f.Default = &now
+		}
+	}
+	return f, nil
+}
+
+// defaultFuncs are the @default functions with a SQL equivalent.
+var defaultFuncs = map[string]string{
+	"now":  "CURRENT_TIMESTAMP",
+	"uuid": "gen_random_uuid()",
+}
+
+// defaultSQL translates the @default value of fd into a SQL expression:
+// strings and enum labels become quoted literals and functions their
+// PostgreSQL equivalent. dbgenerated("expr") is used as written.
+func (r *resolver) defaultSQL(fd *fieldDecl, enumValues []string, val *expr) (string, error) {
+	switch val.kind {
+	case exprString:
+		return "'" + strings.ReplaceAll(val.text, "'", "''") + "'", nil
+	case exprNumber:
+		return val.text, nil
+	case exprIdent:
+		if fd.typeName == "Boolean" && (val.text == "true" || val.text == "false") {
+			return strings.ToUpper(val.text), nil
+		}
+		for _, v := range enumValues {
+			if v == val.text {
+				return "'" + v + "'", nil
+			}
+		}
+		return "", r.errorf(val.pos, "invalid default value %s for field %q of type %s", val.text, fd.name, fd.typeName)
+	case exprCall:
+		if val.text == "dbgenerated" {
+			if len(val.args) != 1 || val.args[0].name != "" || val.args[0].value.kind != exprString {
+				return "", r.errorf(val.pos, "dbgenerated() expects a single string argument")
+			}
+			return val.args[0].value.text, nil
+		}
+		if sql, ok := defaultFuncs[val.text]; ok && len(val.args) == 0 {
+			return sql, nil
+		}
+		return "", r.errorf(val.pos, "unsupported default function %s", val.String())
+	}
+	return "", r.errorf(val.pos, "invalid default value %s for field %q", val.String(), fd.name)
+}
 
-	// Compute JoinTableName for many-to-many relations
+// fieldAttributes are the field-level attributes TORM understands. Native
+// database type attributes (@db.*) are always accepted.
+var fieldAttributes = map[string]bool{
+	"id":        true,
+	"default":   true,
+	"unique":    true,
+	"updatedAt": true,
+	"relation":  true,
+	"map":       true,
+}
+
+// checkAttributes rejects unknown and repeated field attributes.
+func (r *resolver) checkAttributes(attrs []*attribute) error {
+	seen := map[string]bool{}
+	for _, attr := range attrs {
+		if !fieldAttributes[attr.name] && !strings.HasPrefix(attr.name, "db.") {
+			return r.errorf(attr.pos, "unknown attribute %s", attr.displayName())
+		}
+		if seen[attr.name] {
+			return r.errorf(attr.pos, "duplicate attribute %s", attr.displayName())
+		}
+		seen[attr.name] = true
+	}
+	return nil
+}
+
+// fieldList extracts the field names of a block attribute such as
+// @@index([a, b]), checking that each field exists on the model.
+func (r *resolver) fieldList(attr *attribute, fieldNames map[string]bool) ([]string, error) {
+	var list *expr
+	for _, arg := range attr.args {
+		if arg.name == "" || arg.name == "fields" {
+			if list != nil {
+				return nil, r.errorf(arg.pos, "%s takes a single field list", attr.displayName())
+			}
+			list = arg.value
+		}
+	}
+	if list == nil || list.kind != exprArray || len(list.items) == 0 {
+		return nil, r.errorf(attr.pos, "%s requires a list of fields, e.g. %s([a, b])", attr.displayName(), attr.displayName())
+	}
+	var fields []string
+	for _, it := range list.items {
+		// exprCall covers sort/length modifiers such as title(sort: Desc)
+		if it.kind != exprIdent && it.kind != exprString && it.kind != exprCall {
+			return nil, r.errorf(it.pos, "expected field name in %s, found %s", attr.displayName(), it.String())
+		}
+		name := it.text
+		if !fieldNames[name] {
+			return nil, r.errorf(it.pos, "unknown field %q in %s", name, attr.displayName())
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// singleArg checks that attr has exactly one positional argument of the given kind.
+func (r *resolver) singleArg(attr *attribute, kind exprKind) error {
+	if len(attr.args) != 1 || attr.args[0].name != "" || attr.args[0].value.kind != kind {
+		return r.errorf(attr.pos, "%s expects a single argument", attr.displayName())
+	}
+	return nil
+}
+
+func findAttr(attrs []*attribute, name string) *attribute {
+	for _, a := range attrs {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// assignJoinTables computes the join table and columns of many-to-many
+// relations, pairs of list relations that are each other's opposite. The
+// table is named after the two models, as in post_tag, with the columns
+// post_id and tag_id. Self-relations and models with several many-to-many
+// relations between them append the relation name, as in user_user_follows;
+// a self-relation's columns are a_id and b_id, a_id holding the record of the
+// side whose field name sorts first.
+func assignJoinTables(ast *AST) {
 	for i, ent := range ast.Entities {
 		for ri, rel := range ent.Relations {
-			// find target entity index
-			for j, otherEnt := range ast.Entities {
-				if otherEnt.Name != rel.Type {
-					continue
-				}
-				// check if other has reciprocal list relation back to ent.Name
-				hasReciprocal := false
-				for _, r2 := range otherEnt.Relations {
-					if r2.Type == ent.Name {
-						hasReciprocal = true
-						break
-					}
-				}
-				if hasReciprocal {
-					// use lexicographically smaller names to form table name
-					nameA := strings.ToLower(ent.Name)
-					nameB := strings.ToLower(otherEnt.Name)
-					if nameA < nameB {
-						join := nameA + "_" + nameB
-						ast.Entities[i].Relations[ri].JoinTableName = join
-						// set for the reciprocal side
-						for rj, r2 := range ast.Entities[j].Relations {
-							if r2.Type == ent.Name {
-								ast.Entities[j].Relations[rj].JoinTableName = join
-								break
-							}
-						}
-					}
+			if !rel.List {
+				continue
+			}
+			opp := oppositeRelation(ast.Entities, ent.Name, rel)
+			if opp == nil || !opp.List {
+				continue
+			}
+			a, b := strings.ToLower(ent.Name), strings.ToLower(rel.Type)
+			if a > b {
+				a, b = b, a
+			}
+			join := a + "_" + b
+			if rel.RelationName != "" && (ent.Name == rel.Type || manyToManyCount(ast.Entities, ent.Name, rel.Type) > 1) {
+				join += "_" + sqlName(rel.RelationName)
+			}
+			self, target := strings.ToLower(ent.Name)+"_id", strings.ToLower(rel.Type)+"_id"
+			if ent.Name == rel.Type {
+				self, target = "a_id", "b_id"
+				if rel.Name > opp.Name {
+					self, target = target, self
 				}
-				break
 			}
+			r := &ast.Entities[i].Relations[ri]
+			r.JoinTableName, r.JoinColumn, r.JoinTarget = join, self, target
 		}
 	}
+}
 
-	return ast, nil
+// sqlName lowercases s and replaces every character other than a-z, 0-9 and
+// "_" with "_", so that it can be used unquoted in SQL.
+func sqlName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(s))
+}
+
+// manyToManyCount returns the number of list relations on model a to model b
+// whose opposite is also a list.
+func manyToManyCount(ents []Entity, a, b string) int {
+	n := 0
+	for _, ent := range ents {
+		if ent.Name != a {
+			continue
+		}
+		for _, rel := range ent.Relations {
+			if opp := oppositeRelation(ents, a, rel); rel.List && rel.Type == b && opp != nil && opp.List {
+				n++
+			}
+		}
+	}
+	return n
 }
@@ -12,6 +12,7 @@ func TestParseSchema_SimpleModels(t *testing.T) {
 			name      String
 			age       Int?
 			createdAt DateTime @default(now())
+			updatedAt DateTime @updatedAt
 			profile   Profile @relation(fields: [profileId], references: [id])
 			profileId String   @db.Uuid
 		}
@@ -51,7 +52,7 @@ func TestParseSchema_SimpleModels(t *testing.T) {
 			Type:       "uuid.UUID",
 			PrimaryKey: true,
 			Default: func() *string {
-				s := "uuid()"
+				s := "gen_random_uuid()"
 				return &s
 			}(),
 		},
@@ -66,9 +67,16 @@ func TestParseSchema_SimpleModels(t *testing.T) {
 		{
 			Name:    "createdAt",
 			Type:    "time.Time",
-			Default: func() *string { s := "now()"; return &s }(),
+			Default: func() *string { s := "CURRENT_TIMESTAMP"; return &s }(),
 			NotNull: true,
 		},
+		{
+			Name:      "updatedAt",
+			Type:      "time.Time",
+			Default:   func() *string { s := "CURRENT_TIMESTAMP"; return &s }(),
+			NotNull:   true,
+			UpdatedAt: true,
+		},
 		// Note: "profile" relation is skipped by the parser (contains @relation)
 		{
 			Name: "profileId",
@@ -88,6 +96,9 @@ func TestParseSchema_SimpleModels(t *testing.T) {
 		if f.Type != exp.Type {
 			t.Errorf("field[%d].Type = %q, want %q", i, f.Type, exp.Type)
 		}
+		if f.UpdatedAt != exp.UpdatedAt {
+			t.Errorf("field[%d].UpdatedAt = %v, want %v", i, f.UpdatedAt, exp.UpdatedAt)
+		}
 		if f.PrimaryKey != exp.PrimaryKey {
 			t.Errorf("field[%d].PrimaryKey = %v, want %v", i, f.PrimaryKey, exp.PrimaryKey)
 		}
@@ -121,3 +132,592 @@ func TestParseSchema_SimpleModels(t *testing.T) {
 		t.Errorf("Profile.bio parsed incorrectly: %+v", profileEntity.Fields[1])
 	}
 }
+
+func TestParseSchema_ColumnTypes(t *testing.T) {
+	raw := []byte(`
+model Account {
+  id      BigInt   @id
+  balance Decimal
+  ratio   Float
+  avatar  Bytes
+  meta    Json
+  ref     String   @db.Uuid
+}
+`)
+
+	ast, err := ParseSchema(raw)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	want := map[string]string{
+		"id":      "BIGINT",
+		"balance": "NUMERIC",
+		"ratio":   "REAL",
+		"avatar":  "BYTEA",
+		"meta":    "JSONB",
+		"ref":     "UUID",
+	}
+	for _, f := range ast.Entities[0].Fields {
+		if f.DBType != want[f.Name] {
+			t.Errorf("field %s DBType = %q, want %q", f.Name, f.DBType, want[f.Name])
+		}
+	}
+}
+
+func TestParseSchema_StringsAndComments(t *testing.T) {
+	raw := []byte(`
+model Note {
+  id    Int    @id @default(autoincrement()) // trailing comment
+  body  String @default("a } brace") // } not a block end
+  tag   String
+}
+`)
+
+	ast, err := ParseSchema(raw)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if len(ast.Entities) != 1 || len(ast.Entities[0].Fields) != 3 {
+		t.Fatalf("expected 1 entity with 3 fields, got %+v", ast.Entities)
+	}
+	note := ast.Entities[0]
+	if !note.Fields[0].AutoIncrement || !note.Fields[0].PrimaryKey {
+		t.Errorf("id parsed incorrectly: %+v", note.Fields[0])
+	}
+	if note.Fields[1].Default == nil || *note.Fields[1].Default != `'a } brace'` {
+		t.Errorf("body default parsed incorrectly: %+v", note.Fields[1])
+	}
+	if note.Fields[2].Name != "tag" || note.Fields[2].Pos != (Pos{Line: 5, Col: 3}) {
+		t.Errorf("tag parsed incorrectly: %+v", note.Fields[2])
+	}
+}
+
+func TestParseSchema_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{
+			name: "unknown attribute",
+			schema: `model User {
+  id    Int    @id
+  email String @uniqe
+}`,
+			want: "schema.prisma:3:16: unknown attribute @uniqe",
+		},
+		{
+			name: "unknown type",
+			schema: `model User {
+  id   Int  @id
+  role Rolee
+}`,
+			want: `schema.prisma:3:8: unknown type "Rolee"`,
+		},
+		{
+			name: "missing closing brace",
+			schema: `model User {
+  id Int @id
+`,
+			want: `schema.prisma:1:7: model User is missing a closing "}"`,
+		},
+		{
+			name: "unterminated string",
+			schema: `model User {
+  id   Int    @id
+  name String @default("x)
+}`,
+			want: "schema.prisma:3:24: unterminated string",
+		},
+		{
+			name: "scalar list",
+			schema: `model User {
+  id   Int      @id
+  tags String[]
+}`,
+			want: `schema.prisma:3:8: scalar list String[] is not supported`,
+		},
+		{
+			name: "autoincrement on a string",
+			schema: `model User {
+  id String @id @default(autoincrement())
+}`,
+			want: `schema.prisma:2:26: autoincrement() requires an Int or BigInt field, "id" has type String`,
+		},
+		{
+			name: "untranslatable default function",
+			schema: `model User {
+  id String @id @default(cuid())
+}`,
+			want: "schema.prisma:2:26: unsupported default function cuid()",
+		},
+		{
+			name: "unknown enum default",
+			schema: `enum Role {
+  USER
+}
+
+model User {
+  id   Int  @id
+  role Role @default(GUEST)
+}`,
+			want: `schema.prisma:7:22: invalid default value GUEST for field "role" of type Role`,
+		},
+		{
+			name: "duplicate field",
+			schema: `model User {
+  id Int @id
+  id Int
+}`,
+			want: `schema.prisma:3:3: duplicate field "id" in model User`,
+		},
+		{
+			name: "unknown block attribute",
+			schema: `model User {
+  id Int @id
+  @@indx([id])
+}`,
+			want: "schema.prisma:3:3: unknown attribute @@indx",
+		},
+		{
+			name: "index on unknown field",
+			schema: `model User {
+  id Int @id
+  @@index([name])
+}`,
+			want: `schema.prisma:3:12: unknown field "name" in @@index`,
+		},
+		{
+			name: "two fields on one line",
+			schema: `model User {
+  id Int @id name String
+}`,
+			want: `schema.prisma:2:14: unexpected "name", expected end of line`,
+		},
+		{
+			name: "id and @@id",
+			schema: `model User {
+  id    Int    @id
+  email String
+
+  @@id([id, email])
+}`,
+			want: "schema.prisma:5:3: model User already has a primary key",
+		},
+		{
+			name: "optional @@id field",
+			schema: `model Member {
+  orgId  Int
+  userId Int?
+
+  @@id([orgId, userId])
+}`,
+			want: `schema.prisma:5:3: @@id field "userId" must not be optional`,
+		},
+		{
+			name: "duplicate column",
+			schema: `model User {
+  id        Int    @id
+  createdAt String @map("created")
+  created   String
+}`,
+			want: `schema.prisma:4:3: field "created" maps to column "created" of field "createdAt"`,
+		},
+		{
+			name: "duplicate table",
+			schema: `model User {
+  id Int @id
+}
+
+model Account {
+  id Int @id
+
+  @@map("user")
+}`,
+			want: `schema.prisma:5:7: model Account maps to table "user" of model User`,
+		},
+		{
+			name:   "unknown block",
+			schema: `modle User {}`,
+			want:   `schema.prisma:1:1: unknown block type "modle"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSchema([]byte(tt.schema))
+			if err == nil {
+				t.Fatalf("expected error %q, got nil", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("error = %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSchema_UniqueConstraints(t *testing.T) {
+	raw := []byte(`
+model Creator {
+  id        String @id @default(uuid()) @db.Uuid
+  username  String @unique
+  firstName String
+  lastName  String
+  handle    String
+
+  @@unique([firstName, lastName])
+  @@unique([handle])
+}
+`)
+
+	ast, err := ParseSchema(raw)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	creator := ast.Entities[0]
+	unique := map[string]bool{}
+	for _, f := range creator.Fields {
+		unique[f.Name] = f.Unique
+	}
+	if !unique["username"] || !unique["handle"] {
+		t.Errorf("expected username and handle to be unique, got %v", unique)
+	}
+	if unique["id"] || unique["firstName"] {
+		t.Errorf("unexpected unique flags: %v", unique)
+	}
+	if len(creator.UniqueConstraints) != 1 {
+		t.Fatalf("expected 1 compound unique constraint, got %d", len(creator.UniqueConstraints))
+	}
+	if got := creator.UniqueConstraints[0].Fields; len(got) != 2 || got[0] != "firstName" || got[1] != "lastName" {
+		t.Errorf("compound unique fields = %v, want [firstName lastName]", got)
+	}
+}
+
+func TestParseSchema_CompositePrimaryKey(t *testing.T) {
+	raw := []byte(`
+model PostTag {
+  postId Int
+  tagId  Int
+  weight Int @default(1)
+
+  @@id([postId, tagId])
+}
+
+model Account {
+  handle String
+
+  @@id([handle])
+}
+`)
+
+	ast, err := ParseSchema(raw)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	postTag := ast.Entities[0]
+	if got := postTag.PrimaryKey; len(got) != 2 || got[0] != "postId" || got[1] != "tagId" {
+		t.Errorf("PostTag primary key = %v, want [postId tagId]", got)
+	}
+	for _, f := range postTag.Fields {
+		if f.PrimaryKey {
+			t.Errorf("field %s of a composite primary key should not be marked @id", f.Name)
+		}
+	}
+	account := ast.Entities[1]
+	if account.PrimaryKey != nil || !account.Fields[0].PrimaryKey {
+		t.Errorf("single-field @@id should mark handle as @id, got PrimaryKey=%v field=%+v", account.PrimaryKey, account.Fields[0])
+	}
+}
+
+func TestParseSchema_MappedNames(t *testing.T) {
+	raw := []byte(`
+model BlogPost {
+  id        Int      @id @map("post_id")
+  createdAt DateTime @default(now()) @map("created_at")
+  updatedAt DateTime @map("updatedAt")
+  title     String
+
+  @@map("BlogPosts")
+}
+`)
+
+	ast, err := ParseSchema(raw)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	post := ast.Entities[0]
+	// Mapped names are kept as written; default names are lowercased
+	if post.Name != "BlogPost" || post.Table != "BlogPosts" {
+		t.Errorf("BlogPost name/table = %q/%q, want BlogPost/BlogPosts", post.Name, post.Table)
+	}
+	want := map[string]string{"id": "post_id", "createdAt": "created_at", "updatedAt": "updatedAt", "title": "title"}
+	for _, f := range post.Fields {
+		if f.Column != want[f.Name] {
+			t.Errorf("field %s column = %q, want %q", f.Name, f.Column, want[f.Name])
+		}
+	}
+}
+
+func TestParseSchema_OptionalFields(t *testing.T) {
+	raw := []byte(`
+generator client {
+  provider      = "torm"
+  optionalTypes = "sqlnull"
+}
+
+model Profile {
+  id        Int       @id @default(autoincrement())
+  handle    String
+  bio       String?
+  deletedAt DateTime?
+}
+`)
+
+	ast, err := ParseSchema(raw)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	notNull := map[string]bool{}
+	for _, f := range ast.Entities[0].Fields {
+		notNull[f.Name] = f.NotNull
+	}
+	want := map[string]bool{"id": true, "handle": true, "bio": false, "deletedAt": false}
+	for name, w := range want {
+		if notNull[name] != w {
+			t.Errorf("field %s: NotNull = %v, want %v", name, notNull[name], w)
+		}
+	}
+	if len(ast.Generators) != 1 || ast.Generators[0].Options["optionalTypes"] != "sqlnull" {
+		t.Errorf("expected generator option optionalTypes = sqlnull, got %+v", ast.Generators)
+	}
+}
+
+func TestParseSchema_Relations(t *testing.T) {
+	raw := []byte(`
+model Creator {
+  id    String @id @default(uuid()) @db.Uuid
+  posts Post[]
+}
+
+model Post {
+  id       String  @id @default(uuid()) @db.Uuid
+  authorId String  @db.Uuid
+  author   Creator @relation(fields: [authorId], references: [id], onDelete: Cascade, onUpdate: Restrict)
+  parentId String? @db.Uuid
+  parent   Post?   @relation("thread", fields: [parentId], references: [id])
+  replies  Post[]  @relation("thread")
+}
+`)
+
+	ast, err := ParseSchema(raw)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	post := ast.Entities[1]
+	if len(post.Relations) != 3 {
+		t.Fatalf("expected 3 relations on Post, got %d", len(post.Relations))
+	}
+	author := post.Relations[0]
+	if author.List || author.Type != "Creator" || author.OnDelete != "Cascade" || author.OnUpdate != "Restrict" {
+		t.Errorf("unexpected author relation: %+v", author)
+	}
+	if len(author.Fields) != 1 || author.Fields[0] != "authorId" || len(author.References) != 1 || author.References[0] != "id" {
+		t.Errorf("author fields/references = %v/%v, want [authorId]/[id]", author.Fields, author.References)
+	}
+	if parent := post.Relations[1]; !parent.Optional || parent.RelationName != "thread" {
+		t.Errorf("unexpected parent relation: %+v", parent)
+	}
+	if opp := oppositeRelation(ast.Entities, "Post", post.Relations[2]); opp == nil || opp.Name != "parent" {
+		t.Errorf("expected replies to pair with parent, got %+v", opp)
+	}
+	if opp := oppositeRelation(ast.Entities, "Creator", ast.Entities[0].Relations[0]); opp == nil || opp.Name != "author" {
+		t.Errorf("expected posts to pair with author, got %+v", opp)
+	}
+}
+
+func TestParseSchema_ManyToManyRelations(t *testing.T) {
+	raw := []byte(`
+model User {
+  id        Int    @id
+  followers User[] @relation("follows; drop")
+  following User[] @relation("follows; drop")
+}
+
+model Post {
+  id           Int   @id
+  tags         Tag[]
+  featuredTags Tag[] @relation("Featured-Tags")
+}
+
+model Tag {
+  id         Int    @id
+  posts      Post[]
+  featuredIn Post[] @relation("Featured-Tags")
+}
+`)
+
+	ast, err := ParseSchema(raw)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	tests := []struct {
+		ent, rel              string
+		table, column, target string
+	}{
+		{"User", "followers", "user_user_follows__drop", "a_id", "b_id"},
+		{"User", "following", "user_user_follows__drop", "b_id", "a_id"},
+		{"Post", "tags", "post_tag", "post_id", "tag_id"},
+		{"Tag", "posts", "post_tag", "tag_id", "post_id"},
+		{"Post", "featuredTags", "post_tag_featured_tags", "post_id", "tag_id"},
+		{"Tag", "featuredIn", "post_tag_featured_tags", "tag_id", "post_id"},
+	}
+	for _, tt := range tests {
+		for _, ent := range ast.Entities {
+			for _, rel := range ent.Relations {
+				if ent.Name != tt.ent || rel.Name != tt.rel {
+					continue
+				}
+				if rel.JoinTableName != tt.table || rel.JoinColumn != tt.column || rel.JoinTarget != tt.target {
+					t.Errorf("%s.%s joins through %s(%s, %s), want %s(%s, %s)", tt.ent, tt.rel,
+						rel.JoinTableName, rel.JoinColumn, rel.JoinTarget, tt.table, tt.column, tt.target)
+				}
+			}
+		}
+	}
+}
+
+func TestParseSchema_RelationErrors(t *testing.T) {
+	const creator = `
+model Creator {
+  id    String @id @default(uuid()) @db.Uuid
+  email String @unique
+  name  String
+}
+`
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{
+			name: "type mismatch",
+			schema: `model Post {
+  id       Int     @id
+  authorId String
+  author   Creator @relation(fields: [authorId], references: [id])
+}` + creator,
+			want: `schema.prisma:4:39: field "authorId" has type string but references Creator.id of type uuid.UUID`,
+		},
+		{
+			name: "unknown reference",
+			schema: `model Post {
+  id       Int     @id
+  authorId String  @db.Uuid
+  author   Creator @relation(fields: [authorId], references: [uid])
+}` + creator,
+			want: `schema.prisma:4:63: unknown field uid in references of model Creator`,
+		},
+		{
+			name: "mismatched references",
+			schema: `model Post {
+  id       Int     @id
+  authorId String  @db.Uuid
+  author   Creator @relation(fields: [authorId], references: [id, email])
+}` + creator,
+			want: `schema.prisma:4:50: @relation has 1 fields but 2 references`,
+		},
+		{
+			name: "non-unique reference",
+			schema: `model Post {
+  id         Int     @id
+  authorName String
+  author     Creator @relation(fields: [authorName], references: [name])
+}` + creator,
+			want: `schema.prisma:4:54: references of relation "author" must be the primary key or a unique field of Creator`,
+		},
+		{
+			name: "invalid action",
+			schema: `model Post {
+  id       Int     @id
+  authorId String  @db.Uuid
+  author   Creator @relation(fields: [authorId], references: [id], onDelete: Drop)
+}` + creator,
+			want: `schema.prisma:4:68: invalid onDelete action Drop, expected Cascade, Restrict, NoAction, SetNull or SetDefault`,
+		},
+		{
+			name: "required relation over optional field",
+			schema: `model Post {
+  id       Int     @id
+  authorId String? @db.Uuid
+  author   Creator @relation(fields: [authorId], references: [id])
+}` + creator,
+			want: `schema.prisma:4:3: relation "author" is required but its field "authorId" is optional; mark the relation optional with Creator?`,
+		},
+		{
+			name: "missing fields",
+			schema: `model Post {
+  id     Int     @id
+  author Creator
+}` + creator,
+			want: `schema.prisma:3:3: relation "author" must declare fields and references, e.g. @relation(fields: [authorId], references: [id])`,
+		},
+		{
+			name: "list without opposite",
+			schema: `model Post {
+  id       Int       @id
+  creators Creator[]
+}` + creator,
+			want: `schema.prisma:3:3: relation "creators" has no matching relation field on model Creator`,
+		},
+		{
+			name: "ambiguous relation",
+			schema: `model Post {
+  id    Int       @id
+  likes Creator[]
+}
+
+model Creator {
+  id    Int    @id
+  liked Post[]
+  saved Post[]
+}`,
+			want: `schema.prisma:3:3: relation "likes" is ambiguous: Creator has 2 matching relation fields; name both sides with @relation("name")`,
+		},
+		{
+			name: "many-to-many on composite key",
+			schema: `model Post {
+  id   Int   @id
+  tags Tag[]
+}
+
+model Tag {
+  name  String
+  scope String
+  posts Post[]
+
+  @@id([name, scope])
+}`,
+			want: `schema.prisma:3:3: many-to-many relation "tags" requires model Tag to have a single @id field`,
+		},
+		{
+			name: "fields on list side",
+			schema: `model Post {
+  id    Int       @id
+  ids   String
+  likes Creator[] @relation(fields: [ids], references: [id])
+}` + creator,
+			want: `schema.prisma:4:19: fields and references must be declared on the singular side of relation "likes"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSchema([]byte(tt.schema))
+			if err == nil {
+				t.Fatalf("expected error %q, got nil", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("error = %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The declarations below are the raw syntax tree produced by the schema
+// parser. ParseSchema resolves them into the AST used by the generators.
+
+// blockDecl is a top-level block: model, enum, datasource or generator.
+type blockDecl struct {
+	kind   string
+	name   string
+	pos    Pos
+	fields []*fieldDecl    // model fields
+	attrs  []*attribute    // model-level @@attributes
+	values []*enumValue    // enum values
+	props  []*propertyDecl // datasource/generator key = value pairs
+}
+
+// fieldDecl is a single model field line, e.g. `title String? @default("")`.
+type fieldDecl struct {
+	name     string
+	pos      Pos
+	typeName string
+	typePos  Pos
+	list     bool
+	optional bool
+	attrs    []*attribute
+}
+
+// enumValue is a single enum member.
+type enumValue struct {
+	name  string
+	pos   Pos
+	attrs []*attribute
+}
+
+// propertyDecl is a `key = value` line inside a datasource or generator block.
+type propertyDecl struct {
+	name  string
+	pos   Pos
+	value *expr
+}
+
+// attribute is a field (@name) or block (@@name) attribute with its arguments.
+type attribute struct {
+	name  string // dotted name without the @ prefix, e.g. "default" or "db.Uuid"
+	pos   Pos
+	block bool
+	args  []*argument
+}
+
+// argument is a positional or named (`fields: [a]`) attribute argument.
+type argument struct {
+	name  string
+	pos   Pos
+	value *expr
+}
+
+type exprKind int
+
+const (
+	exprString exprKind = iota
+	exprNumber
+	exprIdent
+	exprCall
+	exprArray
+)
+
+// expr is an attribute argument or property value.
+type expr struct {
+	kind  exprKind
+	pos   Pos
+	text  string      // literal text, identifier or function name
+	args  []*argument // function call arguments
+	items []*expr     // array elements
+}
+
+// String renders the expression back to schema source form.
+func (e *expr) String() string {
+	switch e.kind {
+	case exprString:
+		return strconv.Quote(e.text)
+	case exprCall:
+		parts := make([]string, len(e.args))
+		for i, a := range e.args {
+			parts[i] = a.String()
+		}
+		return e.text + "(" + strings.Join(parts, ", ") + ")"
+	case exprArray:
+		parts := make([]string, len(e.items))
+		for i, it := range e.items {
+			parts[i] = it.String()
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return e.text
+	}
+}
+
+func (a *argument) String() string {
+	if a.name != "" {
+		return a.name + ": " + a.value.String()
+	}
+	return a.value.String()
+}
+
+// displayName renders the attribute as written in the schema, e.g. "@@index".
+func (a *attribute) displayName() string {
+	if a.block {
+		return "@@" + a.name
+	}
+	return "@" + a.name
+}
+
+// parser is a recursive-descent parser over the token stream.
+type parser struct {
+	file string
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(pos Pos, format string, args ...interface{}) error {
+	return &ParseError{File: p.file, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expect(kind tokenKind, context string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, p.errorf(t.pos, "expected %s %s, found %s", kind, context, t.describe())
+	}
+	return t, nil
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek().kind == tokNewline {
+		p.next()
+	}
+}
+
+// parseFile parses the whole schema into a list of blocks.
+func (p *parser) parseFile() ([]*blockDecl, error) {
+	var blocks []*blockDecl
+	for {
+		p.skipNewlines()
+		t := p.peek()
+		if t.kind == tokEOF {
+			return blocks, nil
+		}
+		b, err := p.parseBlock()
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+}
+
+func (p *parser) parseBlock() (*blockDecl, error) {
+	kw := p.next()
+	if kw.kind != tokIdent {
+		return nil, p.errorf(kw.pos, "expected block declaration, found %s", kw.describe())
+	}
+	switch kw.text {
+	case "model", "enum", "datasource", "generator":
+	default:
+		return nil, p.errorf(kw.pos, "unknown block type %q", kw.text)
+	}
+	name, err := p.expect(tokIdent, "after "+kw.text)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokLBrace, "to open "+kw.text+" "+name.text); err != nil {
+		return nil, err
+	}
+	b := &blockDecl{kind: kw.text, name: name.text, pos: name.pos}
+	for {
+		p.skipNewlines()
+		t := p.peek()
+		if t.kind == tokRBrace {
+			p.next()
+			break
+		}
+		if t.kind == tokEOF {
+			return nil, p.errorf(b.pos, "%s %s is missing a closing \"}\"", kw.text, name.text)
+		}
+		switch kw.text {
+		case "model":
+			if t.kind == tokAtAt {
+				attr, err := p.parseAttribute()
+				if err != nil {
+					return nil, err
+				}
+				b.attrs = append(b.attrs, attr)
+			} else {
+				f, err := p.parseField()
+				if err != nil {
+					return nil, err
+				}
+				b.fields = append(b.fields, f)
+			}
+		case "enum":
+			if t.kind == tokAtAt {
+				attr, err := p.parseAttribute()
+				if err != nil {
+					return nil, err
+				}
+				b.attrs = append(b.attrs, attr)
+			} else {
+				v, err := p.parseEnumValue()
+				if err != nil {
+					return nil, err
+				}
+				b.values = append(b.values, v)
+			}
+		default:
+			prop, err := p.parseProperty()
+			if err != nil {
+				return nil, err
+			}
+			b.props = append(b.props, prop)
+		}
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// endLine requires a declaration to be followed by a newline or the closing brace.
+func (p *parser) endLine() error {
+	t := p.peek()
+	switch t.kind {
+	case tokNewline:
+		p.next()
+		return nil
+	case tokRBrace:
+		return nil
+	}
+	return p.errorf(t.pos, "unexpected %s, expected end of line", t.describe())
+}
+
+func (p *parser) parseField() (*fieldDecl, error) {
+	name, err := p.expect(tokIdent, "for field name")
+	if err != nil {
+		return nil, err
+	}
+	typ := p.next()
+	if typ.kind != tokIdent {
+		return nil, p.errorf(typ.pos, "expected type for field %q, found %s", name.text, typ.describe())
+	}
+	f := &fieldDecl{name: name.text, pos: name.pos, typeName: typ.text, typePos: typ.pos}
+	if p.peek().kind == tokLParen {
+		return nil, p.errorf(typ.pos, "unsupported type %s(...)", typ.text)
+	}
+	if p.peek().kind == tokLBracket {
+		p.next()
+		if _, err := p.expect(tokRBracket, "to close list type"); err != nil {
+			return nil, err
+		}
+		f.list = true
+	}
+	if p.peek().kind == tokQuestion {
+		q := p.next()
+		if f.list {
+			return nil, p.errorf(q.pos, "list field %q cannot be optional", f.name)
+		}
+		f.optional = true
+	}
+	for p.peek().kind == tokAt || p.peek().kind == tokAtAt {
+		if p.peek().kind == tokAtAt {
+			t := p.peek()
+			return nil, p.errorf(t.pos, "block attribute must be declared on its own line")
+		}
+		attr, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		f.attrs = append(f.attrs, attr)
+	}
+	return f, nil
+}
+
+func (p *parser) parseEnumValue() (*enumValue, error) {
+	name, err := p.expect(tokIdent, "for enum value")
+	if err != nil {
+		return nil, err
+	}
+	v := &enumValue{name: name.text, pos: name.pos}
+	for p.peek().kind == tokAt {
+		attr, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		v.attrs = append(v.attrs, attr)
+	}
+	return v, nil
+}
+
+func (p *parser) parseProperty() (*propertyDecl, error) {
+	name, err := p.expect(tokIdent, "for property name")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokEquals, "after "+name.text); err != nil {
+		return nil, err
+	}
+	val, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &propertyDecl{name: name.text, pos: name.pos, value: val}, nil
+}
+
+// parseAttribute parses `@name`, `@ns.name(args)` or `@@name(args)`.
+func (p *parser) parseAttribute() (*attribute, error) {
+	at := p.next()
+	attr := &attribute{pos: at.pos, block: at.kind == tokAtAt}
+	name, err := p.expect(tokIdent, "for attribute name")
+	if err != nil {
+		return nil, err
+	}
+	parts := []string{name.text}
+	for p.peek().kind == tokDot {
+		p.next()
+		seg, err := p.expect(tokIdent, "in attribute name")
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, seg.text)
+	}
+	attr.name = strings.Join(parts, ".")
+	if p.peek().kind == tokLParen {
+		args, err := p.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		attr.args = args
+	}
+	return attr, nil
+}
+
+// parseArgs parses a parenthesized argument list. Newlines are allowed inside.
+func (p *parser) parseArgs() ([]*argument, error) {
+	open := p.next()
+	var args []*argument
+	for {
+		p.skipNewlines()
+		t := p.peek()
+		if t.kind == tokRParen {
+			p.next()
+			return args, nil
+		}
+		if t.kind == tokEOF {
+			return nil, p.errorf(open.pos, "unclosed \"(\"")
+		}
+		arg := &argument{pos: t.pos}
+		if t.kind == tokIdent && p.toks[p.pos+1].kind == tokColon {
+			arg.name = p.next().text
+			p.next()
+			p.skipNewlines()
+		}
+		val, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		arg.value = val
+		args = append(args, arg)
+		p.skipNewlines()
+		switch sep := p.peek(); sep.kind {
+		case tokComma:
+			p.next()
+		case tokRParen:
+		default:
+			return nil, p.errorf(sep.pos, "expected \",\" or \")\" in argument list, found %s", sep.describe())
+		}
+	}
+}
+
+func (p *parser) parseExpr() (*expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return &expr{kind: exprString, pos: t.pos, text: t.text}, nil
+	case tokNumber:
+		return &expr{kind: exprNumber, pos: t.pos, text: t.text}, nil
+	case tokIdent:
+		if p.peek().kind == tokLParen {
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return &expr{kind: exprCall, pos: t.pos, text: t.text, args: args}, nil
+		}
+		return &expr{kind: exprIdent, pos: t.pos, text: t.text}, nil
+	case tokLBracket:
+		e := &expr{kind: exprArray, pos: t.pos}
+		for {
+			p.skipNewlines()
+			if p.peek().kind == tokRBracket {
+				p.next()
+				return e, nil
+			}
+			item, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			e.items = append(e.items, item)
+			p.skipNewlines()
+			switch sep := p.peek(); sep.kind {
+			case tokComma:
+				p.next()
+			case tokRBracket:
+			default:
+				return nil, p.errorf(sep.pos, "expected \",\" or \"]\" in list, found %s", sep.describe())
+			}
+		}
+	}
+	return nil, p.errorf(t.pos, "expected value, found %s", t.describe())
+}
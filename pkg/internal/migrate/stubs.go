@@ -16,11 +16,7 @@ import (
 
 func EnsureStubs(db *sql.DB, schemaPath, migrationsDir string) error {
 	// Parse the Prisma schema into an AST
-	data, err := ioutil.ReadFile(schemaPath)
-	if err != nil {
-		return fmt.Errorf("read schema: %w", err)
-	}
-	ast, err := generator.ParseSchema(data)
+	ast, err := generator.ParseSchemaFile(schemaPath)
 	if err != nil {
 		return fmt.Errorf("parse schema: %w", err)
 	}
@@ -29,7 +25,7 @@ func EnsureStubs(db *sql.DB, schemaPath, migrationsDir string) error {
 	existingCols := map[string]map[string]bool{}    // tableName -> set of columns
 	existingTypes := map[string]map[string]string{} // tableName -> column -> udt_name
 	for _, ent := range ast.Entities {
-		table := strings.ToLower(ent.Name)
+		table := ent.Table
 		existingCols[table] = map[string]bool{}
 		existingTypes[table] = map[string]string{}
 
@@ -95,9 +91,19 @@ func EnsureStubs(db *sql.DB, schemaPath, migrationsDir string) error {
 		}
 	}
 
+	// Foreign keys may only reference tables that exist when their migration
+	// runs; the others are deferred until every table has been created.
+	available := map[string]bool{}
+	for _, ent := range ast.Entities {
+		if seen[ent.Name] || len(existingCols[ent.Table]) > 0 {
+			available[ent.Name] = true
+		}
+	}
+	var deferred []foreignKey
+
 	// Generate migrations per entity
 	for _, ent := range ast.Entities {
-		tableName := strings.ToLower(ent.Name)
+		tableName := ent.Table
 		existing := existingCols[tableName]
 		types := existingTypes[tableName]
 
@@ -109,6 +115,18 @@ func EnsureStubs(db *sql.DB, schemaPath, migrationsDir string) error {
 			upPath := filepath.Join(migrationsDir, upFile)
 			downPath := filepath.Join(migrationsDir, downFile)
 			upSQL, downSQL := generateCreateTableSQL(ent)
+			available[ent.Name] = true
+			for _, rel := range ent.Relations {
+				if len(rel.Fields) == 0 {
+					continue
+				}
+				if !available[rel.Type] {
+					deferred = append(deferred, foreignKey{ent, rel})
+					continue
+				}
+				addFK, _ := foreignKeySQL(ast.Entities, ent, rel)
+				upSQL += "\n\n" + addFK
+			}
 			if err := ioutil.WriteFile(upPath, []byte(upSQL), 0644); err != nil {
 				return fmt.Errorf("write up stub: %w", err)
 			}
@@ -123,36 +141,36 @@ func EnsureStubs(db *sql.DB, schemaPath, migrationsDir string) error {
 
 			// Added columns
 			for _, f := range ent.Fields {
-				col := strings.ToLower(f.Name)
+				col := f.Column
 				if !existing[col] {
-					colType := typeconv.MapGoTypeToSQL(f.Type)
+					colType, def := columnType(f)
 					null := ""
-					if f.Default == nil {
+					if f.NotNull {
 						null = " NOT NULL"
 					}
-					def := ""
-					if f.Default != nil {
-						def = fmt.Sprintf(" DEFAULT %s", *f.Default)
+					unique := ""
+					if f.Unique {
+						unique = " UNIQUE"
 					}
-					alters = append(alters, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s%s%s;", tableName, col, colType, null, def))
-					drops = append(drops, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", tableName, col))
+					alters = append(alters, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s%s%s%s;", quoteIdent(tableName), quoteIdent(col), colType, null, def, unique))
+					drops = append(drops, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", quoteIdent(tableName), quoteIdent(col)))
 				}
 			}
 
 			// Changed types
 			for _, f := range ent.Fields {
-				col := strings.ToLower(f.Name)
+				col := f.Column
 				if existing[col] {
-					expected := typeconv.MapGoTypeToSQL(f.Type)
+					expected := f.DBType
 					actual := types[col]
 					// Normalize both sides for comparison
 					if typeconv.CanonicalType(expected) != typeconv.CanonicalType(actual) {
 						// Use canonical expected type in migration
 						alters = append(alters,
-							fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", tableName, col, typeconv.CanonicalType(expected)))
+							fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", quoteIdent(tableName), quoteIdent(col), typeconv.CanonicalType(expected)))
 						// Use actual UDT for rollback
 						drops = append(drops,
-							fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", tableName, col, typeconv.CanonicalType(actual)))
+							fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", quoteIdent(tableName), quoteIdent(col), typeconv.CanonicalType(actual)))
 					}
 				}
 			}
@@ -161,17 +179,55 @@ func EnsureStubs(db *sql.DB, schemaPath, migrationsDir string) error {
 			for col := range existing {
 				found := false
 				for _, f := range ent.Fields {
-					if strings.ToLower(f.Name) == col {
+					if f.Column == col {
 						found = true
 						break
 					}
 				}
 				if !found {
-					alters = append(alters, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", tableName, col))
+					alters = append(alters, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", quoteIdent(tableName), quoteIdent(col)))
 					drops = append(drops, fmt.Sprintf("-- note: column %s dropped; manual re-add may be required", col))
 				}
 			}
 
+			// Unique constraints missing from the table
+			uniques, err := uniqueColumnSets(db, tableName)
+			if err != nil {
+				return err
+			}
+			for _, f := range ent.Fields {
+				// New columns are added with UNIQUE already
+				if !f.Unique || !existing[f.Column] || uniques[f.Column] {
+					continue
+				}
+				name := fmt.Sprintf("%s_%s_key", tableName, f.Column)
+				alters = append(alters, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s UNIQUE (%s);", quoteIdent(tableName), quoteIdent(name), quoteIdent(f.Column)))
+				drops = append([]string{fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;", quoteIdent(tableName), quoteIdent(name))}, drops...)
+			}
+			for _, uq := range ent.UniqueConstraints {
+				cols := columns(ent, uq.Fields)
+				if uniques[uniqueKey(cols)] {
+					continue
+				}
+				name := uniqueIndexName(tableName, cols)
+				alters = append(alters, fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s);", quoteIdent(name), quoteIdent(tableName), strings.Join(quoteIdents(cols), ", ")))
+				drops = append([]string{fmt.Sprintf("DROP INDEX IF EXISTS %s;", quoteIdent(name))}, drops...)
+			}
+
+			// Foreign keys over newly added columns
+			for _, rel := range ent.Relations {
+				if len(rel.Fields) == 0 || existing[columns(ent, rel.Fields)[0]] {
+					continue
+				}
+				if !available[rel.Type] {
+					deferred = append(deferred, foreignKey{ent, rel})
+					continue
+				}
+				addFK, dropFK := foreignKeySQL(ast.Entities, ent, rel)
+				alters = append(alters, addFK)
+				drops = append([]string{dropFK}, drops...)
+			}
+
 			// Write alteration stubs if any
 			if len(alters) > 0 {
 				maxVer++
@@ -192,13 +248,48 @@ func EnsureStubs(db *sql.DB, schemaPath, migrationsDir string) error {
 		}
 	}
 
-	// Handle many-to-many join tables
+	// Deferred foreign keys, one migration per referencing table
+	for _, ent := range ast.Entities {
+		var adds, dropFKs []string
+		for _, fk := range deferred {
+			if fk.ent.Name != ent.Name {
+				continue
+			}
+			addFK, dropFK := foreignKeySQL(ast.Entities, fk.ent, fk.rel)
+			adds = append(adds, addFK)
+			dropFKs = append([]string{dropFK}, dropFKs...)
+		}
+		if len(adds) == 0 {
+			continue
+		}
+		maxVer++
+		name := ent.Name + "_foreign_keys"
+		upFile := fmt.Sprintf("%04d_%s.up.sql", maxVer, name)
+		downFile := fmt.Sprintf("%04d_%s.down.sql", maxVer, name)
+		if err := ioutil.WriteFile(filepath.Join(migrationsDir, upFile), []byte(strings.Join(adds, "\n")), 0644); err != nil {
+			return fmt.Errorf("write foreign key up stub: %w", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(migrationsDir, downFile), []byte(strings.Join(dropFKs, "\n")), 0644); err != nil {
+			return fmt.Errorf("write foreign key down stub: %w", err)
+		}
+		fmt.Printf("Generated foreign key migration stubs %s and %s\n", upFile, downFile)
+	}
+
+	// Handle many-to-many join tables, once per table
 	for _, ent := range ast.Entities {
 		for _, rel := range ent.Relations {
+			if rel.JoinTableName == "" {
+				continue
+			}
+			// Create the table from one side only: the model whose name
+			// sorts first, or for a self-relation the side holding a_id.
+			if ent.Name > rel.Type || (ent.Name == rel.Type && rel.JoinColumn > rel.JoinTarget) {
+				continue
+			}
 			// Find the target entity struct
 			var otherEnt *generator.Entity
 			for i := range ast.Entities {
-				if ast.Entities[i].Name == rel.Name {
+				if ast.Entities[i].Name == rel.Type {
 					otherEnt = &ast.Entities[i]
 					break
 				}
@@ -206,95 +297,197 @@ func EnsureStubs(db *sql.DB, schemaPath, migrationsDir string) error {
 			if otherEnt == nil {
 				continue
 			}
-			// Only create once: compare names lex order and check reciprocal relation
-			if ent.Name < otherEnt.Name {
-				// Confirm otherEnt has a relation back to ent
-				hasReciprocal := false
-				for _, r2 := range otherEnt.Relations {
-					if r2.Name == ent.Name {
-						hasReciprocal = true
-						break
-					}
-				}
-				if !hasReciprocal {
-					continue
-				}
-				jtName := strings.ToLower(ent.Name) + "_" + strings.ToLower(otherEnt.Name)
-				// If we've already generated a stub for this join-table, skip it.
-				if seenJoinTables[jtName] {
-					continue
-				}
-				// Otherwise, emit a new migration:
-				maxVer++
-				upFile := fmt.Sprintf("%04d_%s.up.sql", maxVer, jtName)
-				downFile := fmt.Sprintf("%04d_%s.down.sql", maxVer, jtName)
-				upPath := filepath.Join(migrationsDir, upFile)
-				downPath := filepath.Join(migrationsDir, downFile)
-				// Determine primary key types for foreign keys
-				var typeA, typeB string
-				for _, f := range ent.Fields {
-					if f.PrimaryKey {
-						typeA = typeconv.MapGoTypeToSQL(f.Type)
-						break
-					}
-				}
-				for _, f := range otherEnt.Fields {
-					if f.PrimaryKey {
-						typeB = typeconv.MapGoTypeToSQL(f.Type)
-						break
-					}
-				}
-				// Build CREATE TABLE for join
-				upLines := []string{
-					fmt.Sprintf("CREATE TABLE %s (\n    %s_id %s NOT NULL,\n    %s_id %s NOT NULL,\n    PRIMARY KEY (%s_id, %s_id)\n);",
-						jtName,
-						strings.ToLower(ent.Name), typeA,
-						strings.ToLower(otherEnt.Name), typeB,
-						strings.ToLower(ent.Name), strings.ToLower(otherEnt.Name)),
-				}
-				// Add foreign key constraints
-				upLines = append(upLines,
-					fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY (%s_id) REFERENCES %s(id);",
-						jtName, strings.ToLower(ent.Name), strings.ToLower(ent.Name)),
-					fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY (%s_id) REFERENCES %s(id);",
-						jtName, strings.ToLower(otherEnt.Name), strings.ToLower(otherEnt.Name)),
-				)
-				upSQL := strings.Join(upLines, "\n\n")
-				downSQL := fmt.Sprintf("DROP TABLE %s;", jtName)
-				if err := ioutil.WriteFile(upPath, []byte(upSQL), 0644); err != nil {
-					return fmt.Errorf("write many-to-many up stub: %w", err)
+			jtName := rel.JoinTableName
+			// If we've already generated a stub for this join-table, skip it.
+			if seenJoinTables[jtName] {
+				continue
+			}
+			seenJoinTables[jtName] = true
+			// Otherwise, emit a new migration:
+			maxVer++
+			upFile := fmt.Sprintf("%04d_%s.up.sql", maxVer, jtName)
+			downFile := fmt.Sprintf("%04d_%s.down.sql", maxVer, jtName)
+			upPath := filepath.Join(migrationsDir, upFile)
+			downPath := filepath.Join(migrationsDir, downFile)
+			// Determine primary keys for foreign keys
+			var pkA, pkB generator.Field
+			for _, f := range ent.Fields {
+				if f.PrimaryKey {
+					pkA = f
+					break
 				}
-				if err := ioutil.WriteFile(downPath, []byte(downSQL), 0644); err != nil {
-					return fmt.Errorf("write many-to-many down stub: %w", err)
+			}
+			for _, f := range otherEnt.Fields {
+				if f.PrimaryKey {
+					pkB = f
+					break
 				}
-				fmt.Printf("Generated many-to-many migration stubs %s and %s\n", upFile, downFile)
 			}
+			jt, colA, colB := quoteIdent(jtName), quoteIdent(rel.JoinColumn), quoteIdent(rel.JoinTarget)
+			// Build CREATE TABLE for join
+			upLines := []string{
+				fmt.Sprintf("CREATE TABLE %s (\n    %s %s NOT NULL,\n    %s %s NOT NULL,\n    PRIMARY KEY (%s, %s)\n);",
+					jt,
+					colA, pkA.DBType,
+					colB, pkB.DBType,
+					colA, colB),
+			}
+			// Add foreign key constraints; deleting either record removes its links
+			upLines = append(upLines,
+				fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE CASCADE;",
+					jt, colA, quoteIdent(ent.Table), quoteIdent(pkA.Column)),
+				fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE CASCADE;",
+					jt, colB, quoteIdent(otherEnt.Table), quoteIdent(pkB.Column)),
+			)
+			upSQL := strings.Join(upLines, "\n\n")
+			downSQL := fmt.Sprintf("DROP TABLE %s;", jt)
+			if err := ioutil.WriteFile(upPath, []byte(upSQL), 0644); err != nil {
+				return fmt.Errorf("write many-to-many up stub: %w", err)
+			}
+			if err := ioutil.WriteFile(downPath, []byte(downSQL), 0644); err != nil {
+				return fmt.Errorf("write many-to-many down stub: %w", err)
+			}
+			fmt.Printf("Generated many-to-many migration stubs %s and %s\n", upFile, downFile)
 		}
 	}
 	return nil
 }
 
+// uniqueColumnSets returns the column sets of the unique indexes on table,
+// other than its primary key, keyed by uniqueKey.
+func uniqueColumnSets(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(
+		`SELECT array_to_string(array_agg(a.attname::text), ',')
+             FROM pg_index i
+             JOIN pg_class c ON c.oid = i.indrelid
+             JOIN pg_namespace n ON n.oid = c.relnamespace
+             JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = ANY(i.indkey)
+             WHERE n.nspname = 'public' AND c.relname = $1 AND i.indisunique AND NOT i.indisprimary
+             GROUP BY i.indexrelid`,
+		table,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("introspect unique indexes of %s: %w", table, err)
+	}
+	defer rows.Close()
+	sets := map[string]bool{}
+	for rows.Next() {
+		var cols string
+		if err := rows.Scan(&cols); err != nil {
+			return nil, fmt.Errorf("scan unique index of %s: %w", table, err)
+		}
+		sets[uniqueKey(strings.Split(cols, ","))] = true
+	}
+	return sets, rows.Err()
+}
+
+// uniqueKey identifies a set of columns regardless of their order.
+func uniqueKey(cols []string) string {
+	sorted := append([]string(nil), cols...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// quoteIdent quotes a SQL identifier, so that mapped names keep their case and
+// reserved words such as user can be used.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteIdents quotes each identifier of names.
+func quoteIdents(names []string) []string {
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = quoteIdent(name)
+	}
+	return out
+}
+
+// uniqueIndexName names the unique index of a multi-column @@unique, so that
+// create and alter stubs agree on it.
+func uniqueIndexName(table string, cols []string) string {
+	return fmt.Sprintf("uq_%s_%s", table, strings.Join(cols, "_"))
+}
+
+// foreignKey is a relation whose foreign key constraint is yet to be added.
+type foreignKey struct {
+	ent generator.Entity
+	rel generator.Relation
+}
+
+// referentialActions maps schema referential actions to SQL.
+var referentialActions = map[string]string{
+	"Cascade":    "CASCADE",
+	"Restrict":   "RESTRICT",
+	"NoAction":   "NO ACTION",
+	"SetNull":    "SET NULL",
+	"SetDefault": "SET DEFAULT",
+}
+
+// foreignKeySQL returns the statements adding and dropping the foreign key
+// constraint of rel, a relation of ent that declares fields and references.
+func foreignKeySQL(ents []generator.Entity, ent generator.Entity, rel generator.Relation) (string, string) {
+	var target generator.Entity
+	for _, e := range ents {
+		if e.Name == rel.Type {
+			target = e
+		}
+	}
+	tableName := ent.Table
+	cols, refs := columns(ent, rel.Fields), columns(target, rel.References)
+	name := rel.ConstraintMap
+	if name == "" {
+		name = fmt.Sprintf("fk_%s_%s", tableName, strings.Join(cols, "_"))
+	}
+	add := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		quoteIdent(tableName), quoteIdent(name), strings.Join(quoteIdents(cols), ", "), quoteIdent(target.Table), strings.Join(quoteIdents(refs), ", "))
+	if action := referentialActions[rel.OnDelete]; action != "" {
+		add += " ON DELETE " + action
+	}
+	if action := referentialActions[rel.OnUpdate]; action != "" {
+		add += " ON UPDATE " + action
+	}
+	return add + ";", fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;", quoteIdent(tableName), quoteIdent(name))
+}
+
+// columns returns the database columns of the named fields of ent.
+func columns(ent generator.Entity, names []string) []string {
+	cols := make([]string, len(names))
+	for i, name := range names {
+		for _, f := range ent.Fields {
+			if f.Name == name {
+				cols[i] = f.Column
+			}
+		}
+	}
+	return cols
+}
+
+// columnType returns the SQL type of f's column and its DEFAULT clause, if any.
+// Only fields with a @default, which the generated client may leave out of an
+// INSERT, get one; autoincrement() columns are SERIAL or BIGSERIAL.
+func columnType(f generator.Field) (string, string) {
+	switch {
+	case f.AutoIncrement && f.Type == "int64":
+		return "BIGSERIAL", ""
+	case f.AutoIncrement:
+		return "SERIAL", ""
+	case f.Default != nil:
+		return f.DBType, " DEFAULT " + *f.Default
+	}
+	return f.DBType, ""
+}
+
 func generateCreateTableSQL(ent generator.Entity) (string, string) {
-	tableName := strings.ToLower(ent.Name)
+	tableName := ent.Table
+	table := quoteIdent(tableName)
 	var lines []string
 
 	// Primary key column first
 	for _, f := range ent.Fields {
 		if f.PrimaryKey {
-			col := strings.ToLower(f.Name)
-			var colType string
-			var defaultClause string
-
-			// Handle UUID primary keys
-			if f.Type == "uuid.UUID" {
-				colType = "UUID"
-				defaultClause = " DEFAULT uuid_generate_v4()"
-			} else if f.AutoIncrement && (f.Type == "int" || f.Type == "int32" || f.Type == "int64") {
-				colType = "SERIAL"
-			} else {
-				colType = typeconv.MapGoTypeToSQL(f.Type)
-			}
-
+			col := quoteIdent(f.Column)
+			colType, defaultClause := columnType(f)
 			lines = append(lines, fmt.Sprintf("    %s %s PRIMARY KEY%s", col, colType, defaultClause))
 			break
 		}
@@ -305,19 +498,28 @@ func generateCreateTableSQL(ent generator.Entity) (string, string) {
 		if f.PrimaryKey {
 			continue
 		}
-		col := strings.ToLower(f.Name)
-		colType := typeconv.MapGoTypeToSQL(f.Type)
+		col := quoteIdent(f.Column)
+		colType, defaultClause := columnType(f)
 
-		// Default clause
-		defaultClause := ""
-		if f.Default != nil {
-			defaultClause = fmt.Sprintf(" DEFAULT %s", *f.Default)
+		// Required fields are NOT NULL; optional (`Type?`) fields stay nullable
+		nullClause := ""
+		if f.NotNull {
+			nullClause = " NOT NULL"
 		}
 
-		lines = append(lines, fmt.Sprintf("    %s %s%s", col, colType, defaultClause))
+		uniqueClause := ""
+		if f.Unique {
+			uniqueClause = " UNIQUE"
+		}
+
+		lines = append(lines, fmt.Sprintf("    %s %s%s%s%s", col, colType, nullClause, defaultClause, uniqueClause))
+	}
+	// A multi-field @@id becomes a table-level primary key constraint
+	if len(ent.PrimaryKey) > 0 {
+		lines = append(lines, fmt.Sprintf("    PRIMARY KEY (%s)", strings.Join(quoteIdents(columns(ent, ent.PrimaryKey)), ", ")))
 	}
 	// 2) Build the CREATE TABLE statement
-	createTable := fmt.Sprintf("CREATE TABLE %s (\n%s\n);", tableName, strings.Join(lines, ",\n"))
+	createTable := fmt.Sprintf("CREATE TABLE %s (\n%s\n);", table, strings.Join(lines, ",\n"))
 
 	// 3) For each model‐level index, emit a CREATE INDEX statement.
 	// We'll generate names like: idx_<table>_<col>_…_… or you can choose your own convention.
@@ -328,20 +530,30 @@ func generateCreateTableSQL(ent generator.Entity) (string, string) {
 		// (or you could join the column names: idx_<table>_<col1>_<col2>)
 		idxName := fmt.Sprintf("idx_%s_%d", tableName, idxNum+1)
 
-		// Build a comma‐separated list of lowercase fields
-		var cols []string
-		for _, f := range idx.Fields {
-			cols = append(cols, strings.ToLower(f))
-		}
-		colList := strings.Join(cols, ", ")
+		// Build a comma‐separated list of the indexed columns
+		colList := strings.Join(quoteIdents(columns(ent, idx.Fields)), ", ")
 
 		createIndexes = append(createIndexes, fmt.Sprintf(
 			"CREATE INDEX %s ON %s (%s);",
-			idxName, tableName, colList,
+			quoteIdent(idxName), table, colList,
+		))
+		dropIndexes = append(dropIndexes, fmt.Sprintf(
+			"DROP INDEX IF EXISTS %s;",
+			quoteIdent(idxName),
+		))
+	}
+
+	// Multi-column @@unique constraints become unique indexes: uq_<tableName>_<cols>
+	for _, uq := range ent.UniqueConstraints {
+		cols := columns(ent, uq.Fields)
+		idxName := uniqueIndexName(tableName, cols)
+		createIndexes = append(createIndexes, fmt.Sprintf(
+			"CREATE UNIQUE INDEX %s ON %s (%s);",
+			quoteIdent(idxName), table, strings.Join(quoteIdents(cols), ", "),
 		))
 		dropIndexes = append(dropIndexes, fmt.Sprintf(
 			"DROP INDEX IF EXISTS %s;",
-			idxName,
+			quoteIdent(idxName),
 		))
 	}
 
@@ -351,7 +563,7 @@ func generateCreateTableSQL(ent generator.Entity) (string, string) {
 	upSQL := strings.Join(upLines, "\n\n")
 
 	// 5) Assemble down‐migration: first drop each index, then drop the table
-	downLines := append(dropIndexes, fmt.Sprintf("DROP TABLE %s;", tableName))
+	downLines := append(dropIndexes, fmt.Sprintf("DROP TABLE %s;", table))
 	downSQL := strings.Join(downLines, "\n")
 
 	return upSQL, downSQL
@@ -41,6 +41,16 @@ model Book {
 }
 `
 
+// expectUniqueIndexes expects the introspection of the unique indexes of an
+// existing table, each given as comma-separated columns.
+func expectUniqueIndexes(mock sqlmock.Sqlmock, table string, indexes ...string) {
+	rows := sqlmock.NewRows([]string{"columns"})
+	for _, cols := range indexes {
+		rows.AddRow(cols)
+	}
+	mock.ExpectQuery(`FROM pg_index`).WithArgs(table).WillReturnRows(rows)
+}
+
 // TestEnsureStubs_NewTables verifies that when no tables exist in the DB,
 // EnsureStubs emits CREATE TABLE stubs for both Author and Book.
 func TestEnsureStubs_NewTables(t *testing.T) {
@@ -110,7 +120,7 @@ func TestEnsureStubs_NewTables(t *testing.T) {
 			foundUp[model] = true
 
 			contents, _ := ioutil.ReadFile(filepath.Join(migrationsDir, name))
-			if !strings.Contains(string(contents), "CREATE TABLE "+strings.ToLower(model)) {
+			if !strings.Contains(string(contents), "CREATE TABLE \""+strings.ToLower(model)+"\"") {
 				t.Errorf("up stub %s missing CREATE TABLE, got:\n%s", name, string(contents))
 			}
 
@@ -119,7 +129,7 @@ func TestEnsureStubs_NewTables(t *testing.T) {
 			foundDown[model] = true
 
 			contents, _ := ioutil.ReadFile(filepath.Join(migrationsDir, name))
-			if !strings.Contains(string(contents), "DROP TABLE "+strings.ToLower(model)) {
+			if !strings.Contains(string(contents), "DROP TABLE \""+strings.ToLower(model)+"\"") {
 				t.Errorf("down stub %s missing DROP TABLE, got:\n%s", name, string(contents))
 			}
 
@@ -173,6 +183,7 @@ func TestGenerateStubs_AlterTable(t *testing.T) {
              FROM information_schema.columns
              WHERE table_schema = 'public' AND table_name = $1`,
 	)).WithArgs("book").WillReturnRows(bookRows)
+	expectUniqueIndexes(mock, "book")
 
 	// 2) Create a temp directory
 	tmpDir, err := ioutil.TempDir("", "torm-stubs-alter")
@@ -235,7 +246,7 @@ func TestGenerateStubs_AlterTable(t *testing.T) {
 		if authorUpRe.MatchString(name) {
 			foundAuthorUp = true
 			contents, _ := ioutil.ReadFile(filepath.Join(migrationsDir, name))
-			if !strings.Contains(string(contents), "CREATE TABLE author") {
+			if !strings.Contains(string(contents), "CREATE TABLE \"author\"") {
 				t.Errorf("Author up stub missing CREATE TABLE author, got:\n%s", string(contents))
 			}
 		}
@@ -243,9 +254,12 @@ func TestGenerateStubs_AlterTable(t *testing.T) {
 		if bookUpRe.MatchString(name) && !strings.HasPrefix(name, "0001_") {
 			foundBookUp = true
 			contents, _ := ioutil.ReadFile(filepath.Join(migrationsDir, name))
-			if !strings.Contains(string(contents), "ALTER TABLE book ADD COLUMN pages") {
+			if !strings.Contains(string(contents), "ALTER TABLE \"book\" ADD COLUMN \"pages\"") {
 				t.Errorf("Book up stub missing ALTER ADD COLUMN pages, got:\n%s", string(contents))
 			}
+			if strings.Contains(string(contents), "ALTER COLUMN \"id\"") {
+				t.Errorf("Book up stub should keep the UUID id column, got:\n%s", string(contents))
+			}
 		}
 	}
 
@@ -261,3 +275,679 @@ func TestGenerateStubs_AlterTable(t *testing.T) {
 		t.Errorf("unfulfilled SQL mock expectations: %s", err)
 	}
 }
+
+// TestEnsureStubs_UniqueConstraints verifies that @unique columns and
+// @@unique constraints are enforced in the generated CREATE TABLE stub.
+func TestEnsureStubs_UniqueConstraints(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error opening stub database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+	)).WithArgs("author").WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name"}))
+
+	tmpDir, err := ioutil.TempDir("", "torm-stubs-unique")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+model Author {
+  id        String @id @default(uuid()) @db.Uuid
+  email     String @unique
+  firstName String
+  lastName  String
+
+  @@unique([firstName, lastName])
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	migrationsDir := filepath.Join(tmpDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		t.Fatalf("failed to create migrations dir: %v", err)
+	}
+
+	if err := EnsureStubs(db, schemaPath, migrationsDir); err != nil {
+		t.Fatalf("EnsureStubs failed: %v", err)
+	}
+
+	up, err := ioutil.ReadFile(filepath.Join(migrationsDir, "0001_Author.up.sql"))
+	if err != nil {
+		t.Fatalf("failed to read up stub: %v", err)
+	}
+	for _, want := range []string{
+		"\"email\" TEXT NOT NULL UNIQUE",
+		"CREATE UNIQUE INDEX \"uq_author_firstname_lastname\" ON \"author\" (\"firstname\", \"lastname\");",
+	} {
+		if !strings.Contains(string(up), want) {
+			t.Errorf("up stub missing %q, got:\n%s", want, string(up))
+		}
+	}
+	down, err := ioutil.ReadFile(filepath.Join(migrationsDir, "0001_Author.down.sql"))
+	if err != nil {
+		t.Fatalf("failed to read down stub: %v", err)
+	}
+	if !strings.Contains(string(down), "DROP INDEX IF EXISTS \"uq_author_firstname_lastname\";") {
+		t.Errorf("down stub missing unique index drop, got:\n%s", string(down))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled SQL mock expectations: %s", err)
+	}
+}
+
+func TestEnsureStubs_CompositePrimaryKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error opening stub database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+	)).WithArgs("membership").WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name"}))
+
+	tmpDir, err := ioutil.TempDir("", "torm-stubs-composite")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+model Membership {
+  orgId  Int
+  userId Int
+  role   String
+
+  @@id([orgId, userId])
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	migrationsDir := filepath.Join(tmpDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		t.Fatalf("failed to create migrations dir: %v", err)
+	}
+
+	if err := EnsureStubs(db, schemaPath, migrationsDir); err != nil {
+		t.Fatalf("EnsureStubs failed: %v", err)
+	}
+
+	up, err := ioutil.ReadFile(filepath.Join(migrationsDir, "0001_Membership.up.sql"))
+	if err != nil {
+		t.Fatalf("failed to read up stub: %v", err)
+	}
+	for _, want := range []string{
+		"\"orgid\" INTEGER NOT NULL",
+		"\"userid\" INTEGER NOT NULL",
+		"PRIMARY KEY (\"orgid\", \"userid\")",
+	} {
+		if !strings.Contains(string(up), want) {
+			t.Errorf("up stub missing %q, got:\n%s", want, string(up))
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled SQL mock expectations: %s", err)
+	}
+}
+
+// TestEnsureStubs_AddUniqueToExistingTable verifies that @unique and @@unique
+// added to an existing table become constraints, skipping those the table has.
+func TestEnsureStubs_AddUniqueToExistingTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error opening stub database: %v", err)
+	}
+	defer db.Close()
+
+	creatorRows := sqlmock.NewRows([]string{"column_name", "udt_name"}).
+		AddRow("id", "INT4").
+		AddRow("email", "TEXT").
+		AddRow("handle", "TEXT").
+		AddRow("firstname", "TEXT").
+		AddRow("lastname", "TEXT")
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+	)).WithArgs("creator").WillReturnRows(creatorRows)
+	expectUniqueIndexes(mock, "creator", "handle")
+
+	tmpDir, err := ioutil.TempDir("", "torm-stubs-add-unique")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+model Creator {
+  id        Int    @id @default(autoincrement())
+  email     String @unique
+  handle    String @unique
+  firstName String
+  lastName  String
+
+  @@unique([firstName, lastName])
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	migrationsDir := filepath.Join(tmpDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		t.Fatalf("failed to create migrations dir: %v", err)
+	}
+	ioutil.WriteFile(filepath.Join(migrationsDir, "0001_Creator.up.sql"), []byte(""), 0644)
+	ioutil.WriteFile(filepath.Join(migrationsDir, "0001_Creator.down.sql"), []byte(""), 0644)
+
+	if err := EnsureStubs(db, schemaPath, migrationsDir); err != nil {
+		t.Fatalf("EnsureStubs failed: %v", err)
+	}
+
+	up, err := ioutil.ReadFile(filepath.Join(migrationsDir, "0002_Creator.up.sql"))
+	if err != nil {
+		t.Fatalf("failed to read up stub: %v", err)
+	}
+	want := "ALTER TABLE \"creator\" ADD CONSTRAINT \"creator_email_key\" UNIQUE (\"email\");\n" +
+		"CREATE UNIQUE INDEX \"uq_creator_firstname_lastname\" ON \"creator\" (\"firstname\", \"lastname\");"
+	if string(up) != want {
+		t.Errorf("up stub = %q, want %q", string(up), want)
+	}
+	down, err := ioutil.ReadFile(filepath.Join(migrationsDir, "0002_Creator.down.sql"))
+	if err != nil {
+		t.Fatalf("failed to read down stub: %v", err)
+	}
+	wantDown := "DROP INDEX IF EXISTS \"uq_creator_firstname_lastname\";\n" +
+		"ALTER TABLE \"creator\" DROP CONSTRAINT IF EXISTS \"creator_email_key\";"
+	if string(down) != wantDown {
+		t.Errorf("down stub = %q, want %q", string(down), wantDown)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled SQL mock expectations: %s", err)
+	}
+}
+
+func TestEnsureStubs_OptionalFields(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error opening stub database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+	)).WithArgs("profile").WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name"}))
+
+	tmpDir, err := ioutil.TempDir("", "torm-stubs-optional")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+model Profile {
+  id        Int       @id @default(autoincrement())
+  handle    String
+  bio       String?
+  deletedAt DateTime?
+  visits    Int       @default(0)
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	migrationsDir := filepath.Join(tmpDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		t.Fatalf("failed to create migrations dir: %v", err)
+	}
+
+	if err := EnsureStubs(db, schemaPath, migrationsDir); err != nil {
+		t.Fatalf("EnsureStubs failed: %v", err)
+	}
+
+	up, err := ioutil.ReadFile(filepath.Join(migrationsDir, "0001_Profile.up.sql"))
+	if err != nil {
+		t.Fatalf("failed to read up stub: %v", err)
+	}
+	for _, want := range []string{
+		"\"handle\" TEXT NOT NULL,",
+		"\"bio\" TEXT,",
+		"\"deletedat\" TIMESTAMP,",
+		"\"visits\" INTEGER NOT NULL DEFAULT 0",
+	} {
+		if !strings.Contains(string(up), want) {
+			t.Errorf("up stub missing %q, got:\n%s", want, string(up))
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled SQL mock expectations: %s", err)
+	}
+}
+
+// TestEnsureStubs_Defaults verifies that @default values are written as SQL
+// literals and functions.
+func TestEnsureStubs_Defaults(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error opening stub database: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+	)).WithArgs("account").WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name"}))
+
+	tmpDir, err := ioutil.TempDir("", "torm-stubs-defaults")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+enum Role {
+  USER
+  ADMIN
+}
+
+model Account {
+  id        Int      @id @default(autoincrement())
+  status    String   @default("it's a draft")
+  role      Role     @default(USER)
+  active    Boolean  @default(true)
+  token     String   @default(uuid())
+  createdAt DateTime @default(now())
+  score     Float    @default(dbgenerated("random()"))
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	migrationsDir := filepath.Join(tmpDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		t.Fatalf("failed to create migrations dir: %v", err)
+	}
+
+	if err := EnsureStubs(db, schemaPath, migrationsDir); err != nil {
+		t.Fatalf("EnsureStubs failed: %v", err)
+	}
+
+	up, err := ioutil.ReadFile(filepath.Join(migrationsDir, "0001_Account.up.sql"))
+	if err != nil {
+		t.Fatalf("failed to read up stub: %v", err)
+	}
+	for _, want := range []string{
+		"\"status\" TEXT NOT NULL DEFAULT 'it''s a draft',",
+		"\"role\" TEXT NOT NULL DEFAULT 'USER',",
+		"\"active\" BOOLEAN NOT NULL DEFAULT TRUE,",
+		"\"token\" TEXT NOT NULL DEFAULT gen_random_uuid(),",
+		"\"createdat\" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,",
+		"\"score\" REAL NOT NULL DEFAULT random()",
+	} {
+		if !strings.Contains(string(up), want) {
+			t.Errorf("up stub missing %q, got:\n%s", want, string(up))
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled SQL mock expectations: %s", err)
+	}
+}
+
+// TestEnsureStubs_PrimaryKeyDefaults verifies that a primary key gets a column
+// default exactly when the schema declares one, whatever its type.
+func TestEnsureStubs_PrimaryKeyDefaults(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error opening stub database: %v", err)
+	}
+	defer db.Close()
+	for _, table := range []string{"token", "session", "device"} {
+		mock.ExpectQuery(regexp.QuoteMeta(
+			`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+		)).WithArgs(table).WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name"}))
+	}
+
+	tmpDir, err := ioutil.TempDir("", "torm-stubs-pkdefaults")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+model Token {
+  id String @id @default(uuid())
+}
+
+model Session {
+  id String @id @default(uuid()) @db.Uuid
+}
+
+model Device {
+  id String @id @db.Uuid
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	migrationsDir := filepath.Join(tmpDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		t.Fatalf("failed to create migrations dir: %v", err)
+	}
+
+	if err := EnsureStubs(db, schemaPath, migrationsDir); err != nil {
+		t.Fatalf("EnsureStubs failed: %v", err)
+	}
+
+	for file, want := range map[string]string{
+		"0001_Token.up.sql":   "CREATE TABLE \"token\" (\n    \"id\" TEXT PRIMARY KEY DEFAULT gen_random_uuid()\n);",
+		"0002_Session.up.sql": "CREATE TABLE \"session\" (\n    \"id\" UUID PRIMARY KEY DEFAULT gen_random_uuid()\n);",
+		"0003_Device.up.sql":  "CREATE TABLE \"device\" (\n    \"id\" UUID PRIMARY KEY\n);",
+	} {
+		up, err := ioutil.ReadFile(filepath.Join(migrationsDir, file))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file, err)
+		}
+		if string(up) != want {
+			t.Errorf("%s = %q, want %q", file, string(up), want)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled SQL mock expectations: %s", err)
+	}
+}
+
+// TestEnsureStubs_MappedNames verifies that @@map and @map names are used for
+// introspection, new tables, added columns and foreign keys.
+func TestEnsureStubs_MappedNames(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error opening stub database: %v", err)
+	}
+	defer db.Close()
+
+	userRows := sqlmock.NewRows([]string{"column_name", "udt_name"}).
+		AddRow("user_id", "INT4").
+		AddRow("email_address", "TEXT")
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+	)).WithArgs("app_users").WillReturnRows(userRows)
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+	)).WithArgs("blog_posts").WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name"}))
+	expectUniqueIndexes(mock, "app_users", "email_address")
+
+	tmpDir, err := ioutil.TempDir("", "torm-stubs-map")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	schema := `
+model User {
+  id        Int    @id @default(autoincrement()) @map("user_id")
+  email     String @unique @map("email_address")
+  firstName String @map("first_name")
+  posts     Post[]
+
+  @@map("app_users")
+}
+
+model Post {
+  id        Int      @id @default(autoincrement())
+  authorId  Int      @map("author_id")
+  author    User     @relation(fields: [authorId], references: [id])
+  createdAt DateTime @default(now()) @map("created_at")
+
+  @@index([authorId])
+  @@map("blog_posts")
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	migrationsDir := filepath.Join(tmpDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		t.Fatalf("failed to create migrations dir: %v", err)
+	}
+	// User already has a migration; its table lacks first_name
+	ioutil.WriteFile(filepath.Join(migrationsDir, "0001_User.up.sql"), []byte(""), 0644)
+	ioutil.WriteFile(filepath.Join(migrationsDir, "0001_User.down.sql"), []byte(""), 0644)
+
+	if err := EnsureStubs(db, schemaPath, migrationsDir); err != nil {
+		t.Fatalf("EnsureStubs failed: %v", err)
+	}
+
+	for file, wants := range map[string][]string{
+		"0002_User.up.sql": {"ALTER TABLE \"app_users\" ADD COLUMN \"first_name\" TEXT NOT NULL;"},
+		"0003_Post.up.sql": {
+			"CREATE TABLE \"blog_posts\" (",
+			"\"author_id\" INTEGER NOT NULL",
+			"\"created_at\" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
+			"CREATE INDEX \"idx_blog_posts_1\" ON \"blog_posts\" (\"author_id\");",
+			"ALTER TABLE \"blog_posts\" ADD CONSTRAINT \"fk_blog_posts_author_id\" FOREIGN KEY (\"author_id\") REFERENCES \"app_users\" (\"user_id\");",
+		},
+	} {
+		up, err := ioutil.ReadFile(filepath.Join(migrationsDir, file))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file, err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(up), want) {
+				t.Errorf("%s missing %q, got:\n%s", file, want, string(up))
+			}
+		}
+	}
+	if up, _ := ioutil.ReadFile(filepath.Join(migrationsDir, "0002_User.up.sql")); strings.Contains(string(up), "DROP COLUMN") {
+		t.Errorf("mapped columns should not be dropped, got:\n%s", string(up))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled SQL mock expectations: %s", err)
+	}
+}
+
+func TestEnsureStubs_ForeignKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error opening stub database: %v", err)
+	}
+	defer db.Close()
+
+	for _, table := range []string{"post", "creator", "profile"} {
+		mock.ExpectQuery(regexp.QuoteMeta(
+			`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+		)).WithArgs(table).WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name"}))
+	}
+
+	tmpDir, err := ioutil.TempDir("", "torm-stubs-fk")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Post is declared before the Creator table it references, so its
+	// foreign key is deferred; Profile's is added with its table.
+	schema := `
+model Post {
+  id       String  @id @default(uuid()) @db.Uuid
+  authorId String  @db.Uuid
+  author   Creator @relation(fields: [authorId], references: [id], onDelete: Cascade)
+}
+
+model Creator {
+  id      String   @id @default(uuid()) @db.Uuid
+  posts   Post[]
+  profile Profile?
+}
+
+model Profile {
+  id        Int     @id @default(autoincrement())
+  creatorId String? @unique @db.Uuid
+  creator   Creator? @relation(fields: [creatorId], references: [id], onDelete: SetNull, onUpdate: Cascade)
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	migrationsDir := filepath.Join(tmpDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		t.Fatalf("failed to create migrations dir: %v", err)
+	}
+
+	if err := EnsureStubs(db, schemaPath, migrationsDir); err != nil {
+		t.Fatalf("EnsureStubs failed: %v", err)
+	}
+
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(migrationsDir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		return string(data)
+	}
+	if post := read("0001_Post.up.sql"); strings.Contains(post, "FOREIGN KEY") {
+		t.Errorf("Post stub should not reference creator before it exists, got:\n%s", post)
+	}
+	// Foreign key columns have the type of the key they reference
+	if post := read("0001_Post.up.sql"); !strings.Contains(post, "\"authorid\" UUID NOT NULL") {
+		t.Errorf("Post stub should create authorid as UUID, got:\n%s", post)
+	}
+	if profile := read("0003_Profile.up.sql"); !strings.Contains(profile, "\"creatorid\" UUID UNIQUE") {
+		t.Errorf("Profile stub should create creatorid as UUID, got:\n%s", profile)
+	}
+	if want := "ALTER TABLE \"profile\" ADD CONSTRAINT \"fk_profile_creatorid\" FOREIGN KEY (\"creatorid\") REFERENCES \"creator\" (\"id\") ON DELETE SET NULL ON UPDATE CASCADE;"; !strings.Contains(read("0003_Profile.up.sql"), want) {
+		t.Errorf("Profile stub missing %q, got:\n%s", want, read("0003_Profile.up.sql"))
+	}
+	if want := "ALTER TABLE \"post\" ADD CONSTRAINT \"fk_post_authorid\" FOREIGN KEY (\"authorid\") REFERENCES \"creator\" (\"id\") ON DELETE CASCADE;"; read("0004_Post_foreign_keys.up.sql") != want {
+		t.Errorf("deferred foreign key stub = %q, want %q", read("0004_Post_foreign_keys.up.sql"), want)
+	}
+	if want := "ALTER TABLE \"post\" DROP CONSTRAINT IF EXISTS \"fk_post_authorid\";"; read("0004_Post_foreign_keys.down.sql") != want {
+		t.Errorf("deferred foreign key down stub = %q, want %q", read("0004_Post_foreign_keys.down.sql"), want)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled SQL mock expectations: %s", err)
+	}
+}
+
+func TestEnsureStubs_ManyToManyJoinTables(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error opening stub database: %v", err)
+	}
+	defer db.Close()
+
+	for _, table := range []string{"user", "post", "tag"} {
+		mock.ExpectQuery(regexp.QuoteMeta(
+			`SELECT column_name, udt_name
+             FROM information_schema.columns
+             WHERE table_schema = 'public' AND table_name = $1`,
+		)).WithArgs(table).WillReturnRows(sqlmock.NewRows([]string{"column_name", "udt_name"}))
+	}
+
+	tmpDir, err := ioutil.TempDir("", "torm-stubs-m2m")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// A self-relation and two named relations between the same models each
+	// get their own join table.
+	schema := `
+model User {
+  id        Int    @id @default(autoincrement())
+  followers User[] @relation("follows")
+  following User[] @relation("follows")
+}
+
+model Post {
+  id           Int   @id @default(autoincrement())
+  tags         Tag[] @relation("tags")
+  featuredTags Tag[] @relation("featured")
+}
+
+model Tag {
+  id         Int    @id @default(autoincrement())
+  posts      Post[] @relation("tags")
+  featuredIn Post[] @relation("featured")
+}
+`
+	schemaPath := filepath.Join(tmpDir, "schema.prisma")
+	if err := ioutil.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatalf("failed to write schema.prisma: %v", err)
+	}
+	migrationsDir := filepath.Join(tmpDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		t.Fatalf("failed to create migrations dir: %v", err)
+	}
+
+	if err := EnsureStubs(db, schemaPath, migrationsDir); err != nil {
+		t.Fatalf("EnsureStubs failed: %v", err)
+	}
+
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(migrationsDir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		return string(data)
+	}
+	for name, want := range map[string][]string{
+		"0004_user_user_follows.up.sql": {
+			"CREATE TABLE \"user_user_follows\" (\n    \"a_id\" INTEGER NOT NULL,\n    \"b_id\" INTEGER NOT NULL,\n    PRIMARY KEY (\"a_id\", \"b_id\")\n);",
+			"ALTER TABLE \"user_user_follows\" ADD FOREIGN KEY (\"a_id\") REFERENCES \"user\"(\"id\") ON DELETE CASCADE;",
+			"ALTER TABLE \"user_user_follows\" ADD FOREIGN KEY (\"b_id\") REFERENCES \"user\"(\"id\") ON DELETE CASCADE;",
+		},
+		"0005_post_tag_tags.up.sql": {
+			"PRIMARY KEY (\"post_id\", \"tag_id\")",
+			"ALTER TABLE \"post_tag_tags\" ADD FOREIGN KEY (\"post_id\") REFERENCES \"post\"(\"id\") ON DELETE CASCADE;",
+			"ALTER TABLE \"post_tag_tags\" ADD FOREIGN KEY (\"tag_id\") REFERENCES \"tag\"(\"id\") ON DELETE CASCADE;",
+		},
+		"0006_post_tag_featured.up.sql": {"PRIMARY KEY (\"post_id\", \"tag_id\")"},
+	} {
+		up := read(name)
+		for _, w := range want {
+			if !strings.Contains(up, w) {
+				t.Errorf("%s missing %q, got:\n%s", name, w, up)
+			}
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled SQL mock expectations: %s", err)
+	}
+}
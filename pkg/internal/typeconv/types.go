@@ -6,8 +6,16 @@ import "strings"
 func CanonicalType(typ string) string {
 	t := strings.ToUpper(typ)
 	switch t {
-	case "INT4", "INT8", "INTEGER":
+	case "INT4", "INTEGER":
 		return "INTEGER"
+	case "INT8", "BIGINT":
+		return "BIGINT"
+	case "NUMERIC", "DECIMAL":
+		return "NUMERIC"
+	case "BYTEA":
+		return "BYTEA"
+	case "JSONB":
+		return "JSONB"
 	case "BOOL", "BOOLEAN":
 		return "BOOLEAN"
 	case "TEXT":
@@ -25,8 +33,10 @@ func CanonicalType(typ string) string {
 
 func MapGoTypeToSQL(goType string) string {
 	switch goType {
-	case "int", "int32", "int64":
+	case "int", "int32":
 		return "INTEGER"
+	case "int64":
+		return "BIGINT"
 	case "string":
 		return "TEXT"
 	case "bool":
@@ -35,6 +45,12 @@ func MapGoTypeToSQL(goType string) string {
 		return "REAL"
 	case "time.Time":
 		return "TIMESTAMP"
+	case "uuid.UUID":
+		return "UUID"
+	case "[]byte":
+		return "BYTEA"
+	case "json.RawMessage":
+		return "JSONB"
 	default:
 		return "TEXT"
 	}
@@ -0,0 +1,42 @@
+package typeconv
+
+import "testing"
+
+func TestMapGoTypeToSQL(t *testing.T) {
+	tests := map[string]string{
+		"int":             "INTEGER",
+		"int64":           "BIGINT",
+		"float64":         "REAL",
+		"string":          "TEXT",
+		"bool":            "BOOLEAN",
+		"time.Time":       "TIMESTAMP",
+		"uuid.UUID":       "UUID",
+		"[]byte":          "BYTEA",
+		"json.RawMessage": "JSONB",
+	}
+	for goType, want := range tests {
+		if got := MapGoTypeToSQL(goType); got != want {
+			t.Errorf("MapGoTypeToSQL(%q) = %q, want %q", goType, got, want)
+		}
+	}
+}
+
+func TestCanonicalType(t *testing.T) {
+	// udt_name values reported by information_schema, against the mapped types
+	tests := map[string]string{
+		"int4":    "INTEGER",
+		"int8":    "BIGINT",
+		"BIGINT":  "BIGINT",
+		"numeric": "NUMERIC",
+		"bytea":   "BYTEA",
+		"jsonb":   "JSONB",
+		"float8":  "REAL",
+		"text":    "TEXT",
+		"uuid":    "UUID",
+	}
+	for typ, want := range tests {
+		if got := CanonicalType(typ); got != want {
+			t.Errorf("CanonicalType(%q) = %q, want %q", typ, got, want)
+		}
+	}
+}
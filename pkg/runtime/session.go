@@ -1,10 +1,26 @@
 package runtime
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
 
-// Session holds a DB connection and provides query context.
+// Executor runs statements against a database. It is implemented by *sql.DB,
+// *sql.Tx, *sql.Conn and *Session.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Session holds a DB connection and provides query context. A Session passed
+// to a Transaction callback runs its statements inside that transaction.
 type Session struct {
 	DB *sql.DB
+
+	tx    *sql.Tx
+	depth int // savepoint nesting level within tx
 }
 
 // NewSession creates a new session from an existing DB.
@@ -12,4 +28,85 @@ func NewSession(db *sql.DB) *Session {
 	return &Session{DB: db}
 }
 
-// TODO: add Exec, Query, Transaction helpers
+// InTransaction reports whether the session runs inside a transaction.
+func (s *Session) InTransaction() bool {
+	return s.tx != nil
+}
+
+func (s *Session) executor() Executor {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.DB
+}
+
+// ExecContext executes a statement that returns no rows.
+func (s *Session) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.executor().ExecContext(ctx, query, args...)
+}
+
+// QueryContext executes a query that returns rows.
+func (s *Session) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.executor().QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext executes a query that returns at most one row.
+func (s *Session) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.executor().QueryRowContext(ctx, query, args...)
+}
+
+// Transaction runs fn with a session bound to a new transaction, which is
+// committed if fn returns nil and rolled back if fn returns an error or
+// panics; a panic is re-raised after the rollback.
+//
+// Called on a session that is already in a transaction, Transaction nests
+// using a SAVEPOINT instead: a failing fn only rolls back its own work, and
+// opts are ignored.
+func (s *Session) Transaction(ctx context.Context, fn func(tx *Session) error, opts *sql.TxOptions) error {
+	if s.tx != nil {
+		return s.savepoint(ctx, fn)
+	}
+	tx, err := s.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return finish(func() error {
+		return fn(&Session{DB: s.DB, tx: tx})
+	}, tx.Commit, tx.Rollback)
+}
+
+// savepoint runs fn inside a savepoint of the session's transaction.
+func (s *Session) savepoint(ctx context.Context, fn func(tx *Session) error) error {
+	name := fmt.Sprintf("torm_sp_%d", s.depth+1)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	release := func() error {
+		_, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+		return err
+	}
+	rollback := func() error {
+		_, err := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		return err
+	}
+	return finish(func() error {
+		return fn(&Session{DB: s.DB, tx: s.tx, depth: s.depth + 1})
+	}, release, rollback)
+}
+
+// finish runs fn, then commit if it succeeds or rollback if it fails or panics.
+func finish(fn, commit, rollback func() error) error {
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(); err != nil {
+		if rbErr := rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return commit()
+}
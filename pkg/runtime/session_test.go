@@ -0,0 +1,100 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSession_TransactionCommits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO author").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	s := NewSession(db)
+	err = s.Transaction(context.Background(), func(tx *Session) error {
+		if !tx.InTransaction() {
+			t.Errorf("expected the callback session to be in a transaction")
+		}
+		_, err := tx.ExecContext(context.Background(), "INSERT INTO author (name) VALUES ($1)", "Ann")
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatalf("Transaction() error: %v", err)
+	}
+	if s.InTransaction() {
+		t.Errorf("the outer session should not be in a transaction")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestSession_NestedTransactionUsesSavepoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT torm_sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO book").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT torm_sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT torm_sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT torm_sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	errInner := errors.New("inner failed")
+	ctx := context.Background()
+	err = NewSession(db).Transaction(ctx, func(tx *Session) error {
+		err := tx.Transaction(ctx, func(inner *Session) error {
+			if _, err := inner.ExecContext(ctx, "INSERT INTO book (title) VALUES ($1)", "Go"); err != nil {
+				return err
+			}
+			return errInner
+		}, nil)
+		if !errors.Is(err, errInner) {
+			t.Errorf("expected the inner error, got %v", err)
+		}
+		// The transaction is still usable after the savepoint is rolled back.
+		return tx.Transaction(ctx, func(*Session) error { return nil }, nil)
+	}, nil)
+	if err != nil {
+		t.Fatalf("Transaction() error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestSession_TransactionRollsBackOnPanic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("expected the panic to be re-raised, got %v", p)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	}()
+	NewSession(db).Transaction(context.Background(), func(*Session) error {
+		panic("boom")
+	}, nil)
+}